@@ -16,7 +16,9 @@ func main() {
 	}))
 	s2 := service.NewServer("admin", "localhost:8081")
 	app := service.NewApp([]*service.Server{s1, s2}, service.WithShutdownCallbacks(StoreCacheToDBCallback))
-	app.StartAndServe()
+	if err := app.StartAndServe(); err != nil {
+		log.Println(err)
+	}
 }
 
 func StoreCacheToDBCallback(ctx context.Context) {
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net"
+)
+
+// GRPCServer 是 *grpc.Server 的子集，这样不需要依赖 grpc 也能接入 App
+type GRPCServer interface {
+	Serve(lis net.Listener) error
+	// GracefulStop 不再接收新的连接和请求，并且等待已有的请求执行完毕
+	GracefulStop()
+	// Stop 立刻关闭所有的连接
+	Stop()
+}
+
+var _ Shutdownable = &grpcServer{}
+
+// grpcServer 将 GRPCServer 适配为 Shutdownable
+type grpcServer struct {
+	name string
+	addr string
+	srv  GRPCServer
+}
+
+// NewGRPCServer 包装 gRPC server，例如：
+//
+//	app := NewApp(nil, WithServers(NewGRPCServer("rpc", ":8082", grpc.NewServer())))
+func NewGRPCServer(name string, addr string, srv GRPCServer) Shutdownable {
+	return &grpcServer{
+		name: name,
+		addr: addr,
+		srv:  srv,
+	}
+}
+
+func (g *grpcServer) Name() string {
+	return g.name
+}
+
+func (g *grpcServer) Start() error {
+	lis, err := net.Listen("tcp", g.addr)
+	if err != nil {
+		return err
+	}
+	return g.srv.Serve(lis)
+}
+
+// RejectNew gRPC 没有单独拒绝新请求的操作，GracefulStop 会同时完成拒绝新请求和等待已有请求，
+// 所以这里什么也不做，全部放到 Stop 里面
+func (g *grpcServer) RejectNew() {}
+
+// Stop 调用 GracefulStop，ctx 结束的时候还没有返回的话调用 Stop 强制关闭
+func (g *grpcServer) Stop(ctx context.Context) error {
+	log.Printf("服务器%s关闭中", g.name)
+	done := make(chan struct{})
+	go func() {
+		g.srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		log.Printf("服务器%s优雅关闭超时，强制关闭", g.name)
+		g.srv.Stop()
+		return ctx.Err()
+	}
+}
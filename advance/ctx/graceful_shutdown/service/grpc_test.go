@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+// mockGRPCServer 模拟 *grpc.Server，GracefulStop 会一直阻塞到 release 被关闭
+type mockGRPCServer struct {
+	release chan struct{}
+	stopped bool
+}
+
+func (m *mockGRPCServer) Serve(lis net.Listener) error {
+	return lis.Close()
+}
+
+func (m *mockGRPCServer) GracefulStop() {
+	<-m.release
+}
+
+func (m *mockGRPCServer) Stop() {
+	m.stopped = true
+	close(m.release)
+}
+
+func TestGRPCServer_Stop(t *testing.T) {
+	testCases := []struct {
+		name string
+		// graceful 为 true 的时候 GracefulStop 立刻返回
+		graceful    bool
+		wantErr     error
+		wantStopped bool
+	}{
+		{
+			name:     "graceful",
+			graceful: true,
+		},
+		{
+			name:        "timeout",
+			wantErr:     context.DeadlineExceeded,
+			wantStopped: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockGRPCServer{release: make(chan struct{})}
+			if tc.graceful {
+				close(mock.release)
+			}
+			srv := NewGRPCServer("rpc", "localhost:0", mock)
+			assert.NoError(t, srv.Start())
+			// 不影响正在处理的请求，什么也不做
+			srv.RejectNew()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+			defer cancel()
+			assert.Equal(t, tc.wantErr, srv.Stop(ctx))
+			assert.Equal(t, tc.wantStopped, mock.stopped)
+		})
+	}
+}
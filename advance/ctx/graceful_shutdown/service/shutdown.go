@@ -2,11 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -15,6 +18,7 @@ const (
 	shutdownTimeout = 30
 	waitTime        = 10
 	cbTimeout       = 3
+	preHookTimeout  = 3
 )
 
 // Option 典型的 Option 设计模式
@@ -25,16 +29,133 @@ type Option func(*App)
 // - 我们还希望用户知道，他的回调必须要在一定时间内处理完毕，而且他必须显式处理超时错误
 type ShutdownCallback func(ctx context.Context)
 
+// ShutdownCallbackE 和 ShutdownCallback 一样，但是可以返回错误，
+// 例如将缓冲区刷新到数据库失败
+type ShutdownCallbackE func(ctx context.Context) error
+
 // WithShutdownCallbacks 你需要实现这个方法
 func WithShutdownCallbacks(cbs ...ShutdownCallback) Option {
+	return func(app *App) {
+		for _, cb := range cbs {
+			cb := cb
+			app.cbs = append(app.cbs, func(ctx context.Context) error {
+				cb(ctx)
+				return nil
+			})
+		}
+	}
+}
+
+// WithShutdownCallbacksE 注册可以返回错误的回调，回调返回的错误会被记录到日志里面
+func WithShutdownCallbacksE(cbs ...ShutdownCallbackE) Option {
 	return func(app *App) {
 		app.cbs = append(app.cbs, cbs...)
 	}
 }
 
+// WithPreShutdownHook 注册在优雅退出最开始执行的钩子，早于撤销就绪状态和拒绝新请求，
+// 例如从注册中心下线，让负载均衡先摘除流量。
+// 多个钩子并发执行，共享 WithPreShutdownHookTimeout 设置的超时，返回的错误只会记录到日志里面
+func WithPreShutdownHook(hooks ...ShutdownCallbackE) Option {
+	return func(app *App) {
+		app.preHooks = append(app.preHooks, hooks...)
+	}
+}
+
+// WithPreShutdownHookTimeout 设置 WithPreShutdownHook 注册的钩子的超时时间。
+// 非正数会被忽略，使用默认的 3 秒
+func WithPreShutdownHookTimeout(timeout time.Duration) Option {
+	return func(app *App) {
+		if timeout > 0 {
+			app.preHookTimeout = timeout
+		}
+	}
+}
+
+// WithFailFast 任何一个回调返回错误之后，立刻取消其它回调的 ctx
+func WithFailFast() Option {
+	return func(app *App) {
+		app.failFast = true
+	}
+}
+
+// WithShutdownTimeout 设置优雅退出的整个超时时间，超时之后强制退出。
+// 非正数会被忽略，使用默认的 30 秒
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(app *App) {
+		if timeout > 0 {
+			app.shutdownTimeout = timeout
+		}
+	}
+}
+
+// WithWaitTime 设置等待已有请求执行完毕的最长时间。
+// 非正数会被忽略，使用默认的 10 秒
+func WithWaitTime(wait time.Duration) Option {
+	return func(app *App) {
+		if wait > 0 {
+			app.waitTime = wait
+		}
+	}
+}
+
+// WithCallbackTimeout 设置自定义回调的超时时间。
+// 非正数会被忽略，使用默认的 3 秒
+func WithCallbackTimeout(timeout time.Duration) Option {
+	return func(app *App) {
+		if timeout > 0 {
+			app.cbTimeout = timeout
+		}
+	}
+}
+
+// Shutdownable 是 App 能够管理的服务器，例如 http server 或者 gRPC server。
+// App 按照 Start -> RejectNew -> Stop 的顺序驱动它
+type Shutdownable interface {
+	// Start 启动服务器，会一直阻塞直到服务器关闭
+	Start() error
+	// RejectNew 拒绝新请求，正在处理的请求不受影响
+	RejectNew()
+	// Stop 关闭服务器。ctx 结束的时候如果还没有关闭，那么应该强制关闭并且返回 ctx 的错误
+	Stop(ctx context.Context) error
+}
+
+// 下面这些是 Shutdownable 可选实现的接口，App 会在对应的阶段调用
+
+// ReadinessAware 是能够撤销就绪状态的服务器，App 在 PhaseNotReady 阶段调用 MarkNotReady。
+// 撤销就绪状态之后依旧处理请求，但是负载均衡不会再转发新请求过来
+type ReadinessAware interface {
+	MarkNotReady()
+}
+
+// Drainable 是能够等待正在处理的请求结束的服务器，App 在 PhaseDraining 阶段调用 WaitInflight。
+// WaitInflight 最多等待 timeout，调用的时候已经调用过 RejectNew 了
+type Drainable interface {
+	WaitInflight(timeout time.Duration)
+}
+
+// named 提供日志里面使用的服务器名字
+type named interface {
+	Name() string
+}
+
+func serverName(s Shutdownable) string {
+	if n, ok := s.(named); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// WithServers 注册任意的 Shutdownable，例如使用 NewGRPCServer 包装的 gRPC server
+func WithServers(servers ...Shutdownable) Option {
+	return func(app *App) {
+		app.servers = append(app.servers, servers...)
+	}
+}
+
 // App 这里我已经预先定义好了各种可配置字段
 type App struct {
-	servers []*Server
+	servers []Shutdownable
 
 	// 优雅退出整个超时时间，默认30秒
 	shutdownTimeout time.Duration
@@ -44,16 +165,127 @@ type App struct {
 	// 自定义回调超时时间，默认三秒钟
 	cbTimeout time.Duration
 
-	cbs []ShutdownCallback
+	cbs []ShutdownCallbackE
+	// preHooks 在优雅退出最开始执行，和 cbs 不同，它们执行的时候服务器还在正常处理请求
+	preHooks []ShutdownCallbackE
+	// preHooks 的超时时间，默认三秒钟
+	preHookTimeout time.Duration
+	// failFast 为 true 的时候，一个回调出错就取消其它回调
+	failFast bool
+
+	// mutex 保护 servers 和 started
+	mutex sync.Mutex
+	// started 标记 StartAndServe 是否已经开始执行
+	started bool
+
+	// 撤销就绪状态之后，拒绝新请求之前等待的时间，默认为 0。
+	// 这段时间是留给负载均衡感知到下线的
+	preShutdownDelay time.Duration
+	// phase 当前所处的阶段，使用 atomic 读写
+	phase int32
+	// phaseObservers 阶段变化的时候会被同步调用
+	phaseObservers []func(p Phase)
+	// signals 接收退出信号，测试的时候可以直接往里面发信号
+	signals chan os.Signal
+	// exit 用于强制退出，默认是 os.Exit，测试的时候可以替换掉
+	exit func(code int)
+}
+
+// Phase 代表 App 所处的阶段，优雅退出严格按照下面定义的顺序推进
+type Phase int32
+
+const (
+	// PhaseInit 还没有启动
+	PhaseInit Phase = iota
+	// PhaseServing 正常提供服务
+	PhaseServing
+	// PhasePreShutdownHook 执行 WithPreShutdownHook 注册的钩子
+	PhasePreShutdownHook
+	// PhaseNotReady 撤销就绪状态，但是依旧处理请求
+	PhaseNotReady
+	// PhasePreShutdownDelay 等待负载均衡摘除流量
+	PhasePreShutdownDelay
+	// PhaseRejecting 拒绝新请求
+	PhaseRejecting
+	// PhaseDraining 等待已有的请求执行完毕
+	PhaseDraining
+	// PhaseStopping 关闭服务器
+	PhaseStopping
+	// PhaseCallbacks 执行自定义回调
+	PhaseCallbacks
+	// PhaseClosing 释放资源
+	PhaseClosing
+	// PhaseClosed 应用已经关闭
+	PhaseClosed
+)
+
+var phaseNames = [...]string{
+	PhaseInit:             "init",
+	PhaseServing:          "serving",
+	PhasePreShutdownHook:  "pre-shutdown-hook",
+	PhaseNotReady:         "not-ready",
+	PhasePreShutdownDelay: "pre-shutdown-delay",
+	PhaseRejecting:        "rejecting",
+	PhaseDraining:         "draining",
+	PhaseStopping:         "stopping",
+	PhaseCallbacks:        "callbacks",
+	PhaseClosing:          "closing",
+	PhaseClosed:           "closed",
+}
+
+func (p Phase) String() string {
+	if p < 0 || int(p) >= len(phaseNames) {
+		return "unknown"
+	}
+	return phaseNames[p]
 }
 
+// WithPreShutdownDelay 设置撤销就绪状态之后，拒绝新请求之前等待的时间
+func WithPreShutdownDelay(d time.Duration) Option {
+	return func(app *App) {
+		app.preShutdownDelay = d
+	}
+}
+
+// WithPhaseObserver 监听阶段变化，observer 会被同步调用，所以不要执行耗时操作
+func WithPhaseObserver(observer func(p Phase)) Option {
+	return func(app *App) {
+		app.phaseObservers = append(app.phaseObservers, observer)
+	}
+}
+
+// Phase 返回当前所处的阶段
+func (app *App) Phase() Phase {
+	return Phase(atomic.LoadInt32(&app.phase))
+}
+
+func (app *App) setPhase(p Phase) {
+	atomic.StoreInt32(&app.phase, int32(p))
+	for _, observer := range app.phaseObservers {
+		observer(p)
+	}
+}
+
+var (
+	// errAppStarted 在 App 启动之后再添加 Server 时返回
+	errAppStarted = errors.New("service: 应用已经启动，无法添加新的服务器")
+	// errNoServer 在没有配置任何 Server 就启动 App 时返回
+	errNoServer = errors.New("service: 没有配置任何服务器")
+)
+
 // NewApp 创建 App 实例，注意设置默认值，同时使用这些选项
 func NewApp(servers []*Server, opts ...Option) *App {
 	ap := &App{
-		servers:         servers,
-		shutdownTimeout: shutdownTimeout,
-		waitTime:        waitTime,
-		cbTimeout:       cbTimeout,
+		servers:         make([]Shutdownable, 0, len(servers)),
+		shutdownTimeout: time.Second * shutdownTimeout,
+		waitTime:        time.Second * waitTime,
+		cbTimeout:       time.Second * cbTimeout,
+		preHookTimeout:  time.Second * preHookTimeout,
+		signals:         make(chan os.Signal, 1),
+		exit:            os.Exit,
+	}
+	for _, srv := range servers {
+		ap.servers = append(ap.servers, srv)
 	}
 	for _, opt := range opts {
 		opt(ap)
@@ -61,71 +293,160 @@ func NewApp(servers []*Server, opts ...Option) *App {
 	return ap
 }
 
+// AddServer 在 NewApp 之后追加 Server，例如根据配置决定是否启用 admin server
+// 只能在 StartAndServe 之前调用，否则返回错误
+func (app *App) AddServer(s Shutdownable) error {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	if app.started {
+		return errAppStarted
+	}
+	app.servers = append(app.servers, s)
+	return nil
+}
+
 // StartAndServe 你主要要实现这个方法
-func (app *App) StartAndServe() {
+// 如果没有配置任何 Server，那么会直接返回错误，而不是一直阻塞等待信号
+func (app *App) StartAndServe() error {
+	app.mutex.Lock()
+	if len(app.servers) == 0 {
+		app.mutex.Unlock()
+		return errNoServer
+	}
+	app.started = true
+	app.mutex.Unlock()
+	// 先监听信号再开始提供服务，保证进入 PhaseServing 之后收到的信号都能被处理。
+	// SIGKILL 是没有办法捕获的，部署环境一般发送的是 SIGTERM
+	c := app.signals
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(c)
+	app.setPhase(PhaseServing)
 	for _, s := range app.servers {
 		srv := s
 		go func() {
 			if err := srv.Start(); err != nil {
 				if err == http.ErrServerClosed {
-					log.Printf("服务器%s已关闭", srv.name)
+					log.Printf("服务器%s已关闭", serverName(srv))
 				} else {
-					log.Printf("服务器%s异常退出", srv.name)
+					log.Printf("服务器%s异常退出", serverName(srv))
 				}
 			}
 		}()
 	}
 	// 从这里开始优雅退出监听系统信号，强制退出以及超时强制退出。
 	// 优雅退出的具体步骤在 shutdown 里面实现
-	// 所以你需要在这里恰当的位置，调用 shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGKILL)
-	select {
-	case <-c:
-		app.shutdown()
-		go func() {
-			select {
-			//强制退出
-			case <-c:
-				log.Println("主动强制退出")
-				os.Exit(1)
-			//退出超时
-			case <-time.After(time.Second * shutdownTimeout):
-				log.Println("退出超时，强制退出")
-				os.Exit(1)
-			}
-		}()
-	}
+	<-c
+	done := make(chan struct{})
+	go func() {
+		select {
+		//强制退出
+		case <-c:
+			log.Println("主动强制退出")
+			app.exit(1)
+		//退出超时
+		case <-time.After(app.shutdownTimeout):
+			log.Println("退出超时，强制退出")
+			app.exit(1)
+		case <-done:
+		}
+	}()
+	app.shutdown()
+	close(done)
+	return nil
 }
 
 // shutdown 你要设计这里面的执行步骤。
 func (app *App) shutdown() {
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*cbTimeout)
+	// 整个优雅退出最多持续 shutdownTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), app.shutdownTimeout)
 	defer cancel()
-	log.Println("开始关闭应用，停止接收新请求")
-	// 你需要在这里让所有的 server 拒绝新请求
+	log.Println("开始关闭应用，执行钩子")
+	app.setPhase(PhasePreShutdownHook)
+	if errs := app.runCallbacks(app.preHooks, app.preHookTimeout, false); len(errs) > 0 {
+		log.Printf("%d 个钩子执行失败", len(errs))
+	}
+	log.Println("撤销就绪状态")
+	app.setPhase(PhaseNotReady)
 	for _, srv := range app.servers {
-		srv.rejectReq()
+		if ra, ok := srv.(ReadinessAware); ok {
+			ra.MarkNotReady()
+		}
 	}
-	log.Println("等待正在执行请求完结")
-	// 在这里等待一段时间
+	app.setPhase(PhasePreShutdownDelay)
+	if app.preShutdownDelay > 0 {
+		time.Sleep(app.preShutdownDelay)
+	}
+
+	log.Println("停止接收新请求")
+	app.setPhase(PhaseRejecting)
+	// 你需要在这里让所有的 server 拒绝新请求
 	for _, srv := range app.servers {
-		srv.waitInflight()
+		srv.RejectNew()
 	}
+	log.Println("等待正在执行请求完结")
+	app.setPhase(PhaseDraining)
+	// 并发等待，所以最多等待 waitTime，而不是每个 server 等待 waitTime
+	app.waitInflight()
 	log.Println("开始关闭服务器")
+	app.setPhase(PhaseStopping)
 	// 并发关闭服务器，同时要注意协调所有的 server 都关闭之后才能步入下一个阶段
-	for _, srv := range app.servers {
-		_ = srv.stop()
+	if errs := app.stopServers(ctx); len(errs) > 0 {
+		log.Printf("%d 个服务器关闭失败", len(errs))
 	}
 
 	log.Println("开始执行自定义回调")
+	app.setPhase(PhaseCallbacks)
 	// 并发执行回调，要注意协调所有的回调都执行完才会步入下一个阶段
-	app.execCallBack(ctx)
+	if errs := app.execCallBack(); len(errs) > 0 {
+		log.Printf("%d 个回调执行失败", len(errs))
+	}
 
 	// 释放资源
 	log.Println("开始释放资源")
+	app.setPhase(PhaseClosing)
 	app.close()
+	app.setPhase(PhaseClosed)
+}
+
+func (app *App) waitInflight() {
+	var wg sync.WaitGroup
+	for _, srv := range app.servers {
+		d, ok := srv.(Drainable)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(d Drainable) {
+			defer wg.Done()
+			d.WaitInflight(app.waitTime)
+		}(d)
+	}
+	wg.Wait()
+}
+
+// stopServers 并发关闭所有的服务器，ctx 超时之后没有关掉的服务器会被强制关闭。
+// 返回关闭失败的服务器的错误
+func (app *App) stopServers(ctx context.Context) []error {
+	var (
+		mutex sync.Mutex
+		errs  []error
+		wg    sync.WaitGroup
+	)
+	wg.Add(len(app.servers))
+	for _, srv := range app.servers {
+		go func(srv Shutdownable) {
+			defer wg.Done()
+			if err := srv.Stop(ctx); err != nil {
+				log.Printf("关闭服务器%s失败 %v", serverName(srv), err)
+				mutex.Lock()
+				errs = append(errs, err)
+				mutex.Unlock()
+			}
+		}(srv)
+	}
+	// Stop 会在 ctx 结束的时候返回，所以这里不会一直阻塞
+	wg.Wait()
+	return errs
 }
 
 func (app *App) close() {
@@ -134,88 +455,243 @@ func (app *App) close() {
 	log.Println("应用关闭")
 }
 
-// Server 本身可以是很多种 Server，例如 http server
-// 或者 RPC server
-// 理论上来说，如果你设计一个脚手架的框架，那么 Server 应该是一个接口
+var (
+	_ Shutdownable   = &Server{}
+	_ ReadinessAware = &Server{}
+	_ Drainable      = &Server{}
+)
+
+// Server 是 http server 的 Shutdownable 实现，
+// 其它类型的服务器可以自己实现 Shutdownable，gRPC server 可以使用 NewGRPCServer
 type Server struct {
 	srv  *http.Server
 	name string
 	mux  *serverMux
-	wg   *sync.WaitGroup
 }
 
 // serverMux 既可以看做是装饰器模式，也可以看做委托模式
 type serverMux struct {
-	reject bool
+	// inflight 是正在处理的请求数，既用于限制并发请求数，也用于等待请求处理完。
+	// 放在第一个字段，保证 32 位平台上原子操作的对齐
+	inflight int64
+	// maxInflight 大于 0 的时候，正在处理的请求达到这个数量之后，新请求会收到 503
+	maxInflight int64
+	// mutex 保护 notReady 和 reject，保证设置 reject 之后 inflight 不会再增加，
+	// 这样 inflight 减到 0 之后就一直是 0
+	mutex sync.RWMutex
+	// notReady 为 true 说明已经撤销了就绪状态，但是依旧会处理请求
+	notReady bool
+	reject   bool
+	// readinessPath 是就绪检查的路径，为空说明不提供就绪检查
+	readinessPath string
+	// drained 在拒绝新请求之后，正在处理的请求都结束的时候关闭
+	drained   chan struct{}
+	drainOnce sync.Once
 	*http.ServeMux
 }
 
 func (s *serverMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 只是在考虑到 CPU 高速缓存的时候，会存在短时间的不一致性
+	s.mutex.RLock()
+	// 就绪检查不受 reject 影响，也不算作正在处理的请求
+	if s.readinessPath != "" && r.URL.Path == s.readinessPath {
+		ready := !s.notReady && !s.reject
+		s.mutex.RUnlock()
+		if ready {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ready"))
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("not ready"))
+		}
+		return
+	}
 	if s.reject {
+		s.mutex.RUnlock()
 		w.WriteHeader(http.StatusServiceUnavailable)
 		_, _ = w.Write([]byte("服务已关闭"))
 		return
 	}
+	if n := atomic.AddInt64(&s.inflight, 1); s.maxInflight > 0 && n > s.maxInflight {
+		// 持有读锁，reject 不会变化，所以不需要通知 drained
+		atomic.AddInt64(&s.inflight, -1)
+		s.mutex.RUnlock()
+		// 和关闭的时候不同，过一会儿重试还是可以成功的
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("服务繁忙"))
+		return
+	}
+	s.mutex.RUnlock()
+	defer s.done()
 	s.ServeMux.ServeHTTP(w, r)
 }
 
-func NewServer(name string, addr string) *Server {
-	mux := &serverMux{ServeMux: http.NewServeMux()}
-	return &Server{
+// done 结束一个正在处理的请求，
+// 拒绝新请求之后，最后一个请求结束的时候关闭 drained
+func (s *serverMux) done() {
+	if atomic.AddInt64(&s.inflight, -1) == 0 && s.rejected() {
+		s.drain()
+	}
+}
+
+func (s *serverMux) drain() {
+	s.drainOnce.Do(func() {
+		close(s.drained)
+	})
+}
+
+func (s *serverMux) markNotReady() {
+	s.mutex.Lock()
+	s.notReady = true
+	s.mutex.Unlock()
+}
+
+func (s *serverMux) rejectReq() {
+	s.mutex.Lock()
+	s.reject = true
+	n := atomic.LoadInt64(&s.inflight)
+	s.mutex.Unlock()
+	// 没有正在处理的请求，否则由最后一个结束的请求关闭 drained
+	if n == 0 {
+		s.drain()
+	}
+}
+
+func (s *serverMux) rejected() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.reject
+}
+
+func (s *serverMux) ready() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return !s.notReady
+}
+
+// ServerOption 用于配置 Server
+type ServerOption func(s *Server)
+
+// defaultReadinessPath 是默认的就绪检查路径
+const defaultReadinessPath = "/readyz"
+
+// ServerWithReadinessPath 修改就绪检查的路径，默认是 /readyz。
+// 传入空字符串则不提供就绪检查
+func ServerWithReadinessPath(path string) ServerOption {
+	return func(s *Server) {
+		s.mux.readinessPath = path
+	}
+}
+
+// ServerWithMaxInflight 限制正在处理的请求数，超过 n 之后新请求会收到 503，
+// 响应体是"服务繁忙"并且带有 Retry-After 头部，以便和关闭之后的拒绝区分开来。
+// n 小于等于 0 说明不限制，这是默认值。就绪检查不受限制
+func ServerWithMaxInflight(n int) ServerOption {
+	return func(s *Server) {
+		s.mux.maxInflight = int64(n)
+	}
+}
+
+// NewServer 创建 Server，它会自动提供就绪检查：
+// 正常服务的时候返回 200，开始优雅退出之后返回 503，
+// 这样负载均衡可以在拒绝请求之前就不再转发新请求过来
+func NewServer(name string, addr string, opts ...ServerOption) *Server {
+	mux := &serverMux{
+		ServeMux:      http.NewServeMux(),
+		readinessPath: defaultReadinessPath,
+		drained:       make(chan struct{}),
+	}
+	res := &Server{
 		name: name,
 		mux:  mux,
 		srv: &http.Server{
 			Addr:    addr,
 			Handler: mux,
 		},
-		wg: new(sync.WaitGroup),
 	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
 }
 
 func (s *Server) Handle(pattern string, handler http.Handler) {
-	s.wg.Add(1)
-	defer s.wg.Done()
 	s.mux.Handle(pattern, handler)
 }
 
+// Name 返回服务器的名字
+func (s *Server) Name() string {
+	return s.name
+}
+
 func (s *Server) Start() error {
 	return s.srv.ListenAndServe()
 }
 
-func (s *Server) rejectReq() {
-	s.mux.reject = true
+// MarkNotReady 撤销就绪状态，之后就绪检查会返回 503
+func (s *Server) MarkNotReady() {
+	s.mux.markNotReady()
 }
 
-//waitInflight 等待请求处理或超时
-func (s *Server) waitInflight() {
-	ch := make(chan struct{})
-	go func() {
-		s.wg.Wait()
-		ch <- struct{}{}
-	}()
+// RejectNew 拒绝新请求，新请求会收到 503
+func (s *Server) RejectNew() {
+	s.mux.rejectReq()
+}
+
+// WaitInflight 等待正在处理的请求结束，最多等待 timeout。
+// 必须在 RejectNew 之后调用，这样不会再有新的请求进来
+func (s *Server) WaitInflight(timeout time.Duration) {
 	select {
-	case <-ch:
+	case <-s.mux.drained:
 		log.Println(s.name + " 请求已处理完")
-	case <-time.After(time.Second * waitTime):
+	case <-time.After(timeout):
 		log.Println(s.name + "请求处理超时")
 	}
 }
 
-func (s *Server) stop() error {
+// Stop 优雅关闭服务器，等待已有的连接处理完毕。
+// 如果 ctx 结束的时候还没有关闭，那么直接关闭所有的连接，并且返回 ctx 的错误
+func (s *Server) Stop(ctx context.Context) error {
 	log.Printf("服务器%s关闭中", s.name)
-	return s.srv.Shutdown(context.TODO())
+	err := s.srv.Shutdown(ctx)
+	if err != nil && err == ctx.Err() {
+		log.Printf("服务器%s优雅关闭超时，强制关闭", s.name)
+		_ = s.srv.Close()
+	}
+	return err
 }
 
-func (app *App) execCallBack(ctx context.Context) {
+// execCallBack 并发执行回调，所有回调共享一个 cbTimeout 的超时，
+// 回调全部返回之后 ctx 会被取消。返回所有回调的错误
+func (app *App) execCallBack() []error {
+	return app.runCallbacks(app.cbs, app.cbTimeout, app.failFast)
+}
 
+// runCallbacks 并发执行 cbs，它们共享一个 timeout 的超时。
+// failFast 为 true 的时候，一个回调出错就取消其它回调
+func (app *App) runCallbacks(cbs []ShutdownCallbackE, timeout time.Duration, failFast bool) []error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var (
+		mutex sync.Mutex
+		errs  []error
+	)
 	wg := new(sync.WaitGroup)
-	for _, cb := range app.cbs {
+	for _, cb := range cbs {
 		wg.Add(1)
-		go func(cb ShutdownCallback) {
-			cb(ctx)
-			wg.Done()
+		go func(cb ShutdownCallbackE) {
+			defer wg.Done()
+			if err := cb(ctx); err != nil {
+				log.Printf("执行回调失败 %v", err)
+				mutex.Lock()
+				errs = append(errs, err)
+				mutex.Unlock()
+				if failFast {
+					cancel()
+				}
+			}
 		}(cb)
 	}
 	wg.Wait()
+	return errs
 }
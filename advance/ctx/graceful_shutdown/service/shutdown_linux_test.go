@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestApp_SIGTERM(t *testing.T) {
+	var cbDeadline time.Time
+	cbDone := make(chan time.Time, 1)
+	srv := NewServer("test", "localhost:0")
+	app := NewApp([]*Server{srv},
+		WithShutdownCallbacks(func(ctx context.Context) {
+			cbDeadline, _ = ctx.Deadline()
+			<-ctx.Done()
+			cbDone <- time.Now()
+		}),
+		WithPhaseObserver(func(p Phase) {
+			// 进入 PhaseServing 的时候已经开始监听信号
+			if p == PhaseServing {
+				go func() {
+					_ = syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+				}()
+			}
+		}))
+	app.waitTime = time.Millisecond * 10
+	app.cbTimeout = time.Millisecond * 50
+
+	start := time.Now()
+	require.NoError(t, app.StartAndServe())
+	assert.Equal(t, PhaseClosed, app.Phase())
+	assert.True(t, srv.mux.rejected())
+
+	// 回调在配置的 cbTimeout 内结束
+	end := <-cbDone
+	assert.False(t, cbDeadline.After(end))
+	assert.Less(t, cbDeadline.Sub(start), time.Second)
+	assert.Less(t, end.Sub(start), time.Second)
+}
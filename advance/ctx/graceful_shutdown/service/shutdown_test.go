@@ -0,0 +1,811 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestApp_AddServer(t *testing.T) {
+	app := NewApp(nil)
+	before := NewServer("before", "localhost:0")
+	require.NoError(t, app.AddServer(before))
+
+	startErr := make(chan error, 1)
+	go func() {
+		startErr <- before.Start()
+	}()
+	// 模拟 StartAndServe 已经开始执行
+	app.mutex.Lock()
+	app.started = true
+	app.mutex.Unlock()
+
+	after := NewServer("after", "localhost:0")
+	assert.Equal(t, errAppStarted, app.AddServer(after))
+	assert.Equal(t, []Shutdownable{before}, app.servers)
+
+	app.shutdown()
+	assert.True(t, before.mux.rejected())
+	select {
+	case err := <-startErr:
+		assert.Equal(t, http.ErrServerClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("服务器没有被关闭")
+	}
+	assert.False(t, after.mux.rejected())
+}
+
+func TestApp_StartAndServe(t *testing.T) {
+	testCases := []struct {
+		name    string
+		servers []*Server
+		wantErr error
+	}{
+		{
+			name:    "nil servers",
+			wantErr: errNoServer,
+		},
+		{
+			name:    "empty servers",
+			servers: []*Server{},
+			wantErr: errNoServer,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := NewApp(tc.servers)
+			err := app.StartAndServe()
+			assert.Equal(t, tc.wantErr, err)
+			// 启动失败的 App 依旧可以添加 Server
+			assert.NoError(t, app.AddServer(NewServer("test", "localhost:0")))
+		})
+	}
+}
+
+func TestApp_Phase(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		phases []Phase
+	)
+	srv := NewServer("test", "localhost:0")
+	app := NewApp([]*Server{srv},
+		WithPreShutdownDelay(time.Millisecond*10),
+		WithPhaseObserver(func(p Phase) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			phases = append(phases, p)
+			switch p {
+			case PhaseNotReady:
+				assert.False(t, srv.mux.rejected())
+			case PhaseRejecting:
+				assert.True(t, !srv.mux.ready())
+			case PhaseDraining:
+				assert.True(t, srv.mux.rejected())
+			}
+		}))
+	assert.Equal(t, PhaseInit, app.Phase())
+
+	// 模拟收到退出信号
+	app.signals <- syscall.SIGINT
+	require.NoError(t, app.StartAndServe())
+
+	assert.Equal(t, PhaseClosed, app.Phase())
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []Phase{
+		PhaseServing,
+		PhasePreShutdownHook,
+		PhaseNotReady,
+		PhasePreShutdownDelay,
+		PhaseRejecting,
+		PhaseDraining,
+		PhaseStopping,
+		PhaseCallbacks,
+		PhaseClosing,
+		PhaseClosed,
+	}, phases)
+}
+
+func TestApp_PreShutdownHook(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		events []string
+	)
+	record := func(e string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, e)
+	}
+	srv := NewServer("test", "localhost:0")
+	var deadline time.Time
+	app := NewApp([]*Server{srv},
+		WithWaitTime(time.Millisecond*10),
+		WithPreShutdownHookTimeout(time.Minute),
+		WithPreShutdownHook(func(ctx context.Context) error {
+			deadline, _ = ctx.Deadline()
+			// 钩子执行的时候还在正常提供服务
+			assert.True(t, srv.mux.ready())
+			assert.False(t, srv.mux.rejected())
+			record("hook")
+			// 钩子失败不影响后面的步骤
+			return errors.New("mock error")
+		}),
+		WithShutdownCallbacks(func(ctx context.Context) {
+			record("callback")
+		}),
+		WithPhaseObserver(func(p Phase) {
+			if p == PhaseRejecting {
+				record("rejecting")
+			}
+		}))
+	start := time.Now()
+	app.shutdown()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{"hook", "rejecting", "callback"}, events)
+	assert.True(t, srv.mux.rejected())
+	// 超时时间来自 preHookTimeout，而不是 cbTimeout
+	assert.False(t, deadline.Before(start.Add(time.Minute)))
+}
+
+func TestApp_PreShutdownHookTimeout(t *testing.T) {
+	done := make(chan error, 1)
+	app := NewApp([]*Server{NewServer("test", "localhost:0")},
+		WithWaitTime(time.Millisecond*10),
+		WithPreShutdownHookTimeout(time.Millisecond*10),
+		WithPreShutdownHook(func(ctx context.Context) error {
+			<-ctx.Done()
+			done <- ctx.Err()
+			return ctx.Err()
+		}))
+	app.shutdown()
+	assert.Equal(t, context.DeadlineExceeded, <-done)
+	assert.Equal(t, PhaseClosed, app.Phase())
+}
+
+func TestApp_PreShutdownHookFailFast(t *testing.T) {
+	done := make(chan error, 1)
+	app := NewApp([]*Server{NewServer("test", "localhost:0")},
+		WithWaitTime(time.Millisecond*10),
+		WithPreShutdownHookTimeout(time.Millisecond*10),
+		// WithFailFast 只作用于关闭回调，不会取消其它钩子
+		WithFailFast(),
+		WithPreShutdownHook(func(ctx context.Context) error {
+			return errors.New("mock error")
+		}, func(ctx context.Context) error {
+			<-ctx.Done()
+			done <- ctx.Err()
+			return ctx.Err()
+		}))
+	app.shutdown()
+	assert.Equal(t, context.DeadlineExceeded, <-done)
+}
+
+func TestPhase_String(t *testing.T) {
+	assert.Equal(t, "pre-shutdown-hook", PhasePreShutdownHook.String())
+	assert.Equal(t, "pre-shutdown-delay", PhasePreShutdownDelay.String())
+	assert.Equal(t, "closed", PhaseClosed.String())
+	assert.Equal(t, "unknown", Phase(100).String())
+}
+
+func TestApp_execCallBack(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		ctxs     []context.Context
+		deadline time.Time
+	)
+	cb := func(ctx context.Context) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		ctxs = append(ctxs, ctx)
+		deadline, _ = ctx.Deadline()
+	}
+	app := NewApp(nil, WithShutdownCallbacks(cb, cb))
+	app.cbTimeout = time.Minute
+
+	start := time.Now()
+	app.execCallBack()
+	end := time.Now()
+
+	require.Len(t, ctxs, 2)
+	// 超时时间来自 cbTimeout
+	assert.False(t, deadline.Before(start.Add(time.Minute)))
+	assert.False(t, deadline.After(end.Add(time.Minute)))
+	// 回调执行完毕之后 ctx 被取消
+	for _, ctx := range ctxs {
+		assert.Equal(t, context.Canceled, ctx.Err())
+	}
+}
+
+func TestApp_execCallBackTimeout(t *testing.T) {
+	done := make(chan error, 1)
+	app := NewApp(nil, WithShutdownCallbacks(func(ctx context.Context) {
+		<-ctx.Done()
+		done <- ctx.Err()
+	}))
+	app.cbTimeout = time.Millisecond * 10
+	app.execCallBack()
+	assert.Equal(t, context.DeadlineExceeded, <-done)
+}
+
+func TestApp_execCallBackError(t *testing.T) {
+	mockErr := errors.New("mock error")
+	testCases := []struct {
+		name     string
+		failFast bool
+
+		wantErrs []error
+	}{
+		{
+			// 出错的回调不影响其它回调，等待 ctx 的回调一直等到超时
+			name:     "collect errors",
+			wantErrs: []error{mockErr, context.DeadlineExceeded},
+		},
+		{
+			// 第一个错误出现之后就取消其它回调
+			name:     "fail fast",
+			failFast: true,
+			wantErrs: []error{mockErr, context.Canceled},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []Option{
+				WithCallbackTimeout(time.Millisecond * 100),
+				WithShutdownCallbacksE(
+					func(ctx context.Context) error {
+						return mockErr
+					},
+					func(ctx context.Context) error {
+						<-ctx.Done()
+						return ctx.Err()
+					},
+					func(ctx context.Context) error {
+						return nil
+					}),
+				WithShutdownCallbacks(func(ctx context.Context) {}),
+			}
+			if tc.failFast {
+				opts = append(opts, WithFailFast())
+			}
+			app := NewApp(nil, opts...)
+			// 出错的回调一定先返回，所以顺序是确定的
+			assert.Equal(t, tc.wantErrs, app.execCallBack())
+		})
+	}
+}
+
+func TestApp_Options(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []Option
+
+		wantShutdownTimeout time.Duration
+		wantWaitTime        time.Duration
+		wantCbTimeout       time.Duration
+	}{
+		{
+			name:                "default",
+			wantShutdownTimeout: time.Second * 30,
+			wantWaitTime:        time.Second * 10,
+			wantCbTimeout:       time.Second * 3,
+		},
+		{
+			name: "override",
+			opts: []Option{
+				WithShutdownTimeout(time.Minute),
+				WithWaitTime(time.Second),
+				WithCallbackTimeout(time.Millisecond),
+			},
+			wantShutdownTimeout: time.Minute,
+			wantWaitTime:        time.Second,
+			wantCbTimeout:       time.Millisecond,
+		},
+		{
+			// 非正数被忽略
+			name: "non-positive",
+			opts: []Option{
+				WithShutdownTimeout(0),
+				WithWaitTime(-time.Second),
+				WithCallbackTimeout(0),
+			},
+			wantShutdownTimeout: time.Second * 30,
+			wantWaitTime:        time.Second * 10,
+			wantCbTimeout:       time.Second * 3,
+		},
+		{
+			// 后面的覆盖前面的
+			name: "last wins",
+			opts: []Option{
+				WithWaitTime(time.Second),
+				WithWaitTime(time.Minute),
+			},
+			wantShutdownTimeout: time.Second * 30,
+			wantWaitTime:        time.Minute,
+			wantCbTimeout:       time.Second * 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := NewApp(nil, tc.opts...)
+			assert.Equal(t, tc.wantShutdownTimeout, app.shutdownTimeout)
+			assert.Equal(t, tc.wantWaitTime, app.waitTime)
+			assert.Equal(t, tc.wantCbTimeout, app.cbTimeout)
+		})
+	}
+}
+
+func TestApp_WithWaitTime(t *testing.T) {
+	var draining, stopping time.Time
+	srv := NewServer("test", "localhost:0")
+	// 模拟一个一直没有结束的请求
+	atomic.AddInt64(&srv.mux.inflight, 1)
+	defer srv.mux.done()
+	app := NewApp([]*Server{srv}, WithWaitTime(time.Millisecond*50),
+		WithPhaseObserver(func(p Phase) {
+			switch p {
+			case PhaseDraining:
+				draining = time.Now()
+			case PhaseStopping:
+				stopping = time.Now()
+			}
+		}))
+	app.shutdown()
+	elapsed := stopping.Sub(draining)
+	assert.GreaterOrEqual(t, elapsed, time.Millisecond*50)
+	assert.Less(t, elapsed, time.Second)
+}
+
+func TestApp_WithCallbackTimeout(t *testing.T) {
+	var (
+		start    time.Time
+		called   time.Time
+		deadline time.Time
+	)
+	app := NewApp([]*Server{NewServer("test", "localhost:0")},
+		WithCallbackTimeout(time.Millisecond*50),
+		WithShutdownCallbacks(func(ctx context.Context) {
+			called = time.Now()
+			deadline, _ = ctx.Deadline()
+			<-ctx.Done()
+		}),
+		WithPhaseObserver(func(p Phase) {
+			if p == PhaseCallbacks {
+				start = time.Now()
+			}
+		}))
+	app.shutdown()
+	// ctx 在进入 PhaseCallbacks 之后，调用回调之前创建，
+	// 所以 deadline 落在这两个时间点各自加上超时时间的区间里面
+	assert.False(t, deadline.Before(start.Add(time.Millisecond*50)))
+	assert.False(t, deadline.After(called.Add(time.Millisecond*50)))
+}
+
+func TestApp_WithShutdownTimeout(t *testing.T) {
+	exitCode := make(chan int, 1)
+	app := NewApp([]*Server{NewServer("test", "localhost:0")},
+		WithShutdownTimeout(time.Millisecond*20),
+		// 回调比整个优雅退出的时间还要长
+		WithCallbackTimeout(time.Millisecond*200),
+		WithShutdownCallbacks(func(ctx context.Context) {
+			<-ctx.Done()
+		}))
+	app.exit = func(code int) {
+		exitCode <- code
+	}
+	app.signals <- syscall.SIGINT
+	require.NoError(t, app.StartAndServe())
+	select {
+	case code := <-exitCode:
+		assert.Equal(t, 1, code)
+	default:
+		t.Fatal("超时之后没有强制退出")
+	}
+}
+
+func TestApp_WaitInflight(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		events []string
+	)
+	record := func(e string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, e)
+	}
+	started := make(chan struct{})
+	srv := NewServer("test", "localhost:0")
+	srv.Handle("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(time.Millisecond * 100)
+		record("request done")
+		w.WriteHeader(http.StatusOK)
+	}))
+	app := NewApp([]*Server{srv}, WithWaitTime(time.Second*5),
+		WithPhaseObserver(func(p Phase) {
+			if p == PhaseStopping {
+				record("stopping")
+			}
+		}))
+
+	slow := httptest.NewRecorder()
+	go srv.mux.ServeHTTP(slow, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+	app.shutdown()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	// 先等慢请求结束，再关闭服务器
+	assert.Equal(t, []string{"request done", "stopping"}, events)
+	assert.Equal(t, http.StatusOK, slow.Code)
+
+	// 拒绝之后的请求不会被统计
+	rejected := httptest.NewRecorder()
+	srv.mux.ServeHTTP(rejected, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rejected.Code)
+}
+
+func TestServer_Readiness(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []ServerOption
+		path string
+
+		wantServing  int
+		wantNotReady int
+		wantRejected int
+	}{
+		{
+			name:         "default path",
+			path:         "/readyz",
+			wantServing:  http.StatusOK,
+			wantNotReady: http.StatusServiceUnavailable,
+			wantRejected: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "custom path",
+			opts:         []ServerOption{ServerWithReadinessPath("/health/ready")},
+			path:         "/health/ready",
+			wantServing:  http.StatusOK,
+			wantNotReady: http.StatusServiceUnavailable,
+			wantRejected: http.StatusServiceUnavailable,
+		},
+		{
+			// 关闭就绪检查之后就是普通的路径
+			name:         "disabled",
+			opts:         []ServerOption{ServerWithReadinessPath("")},
+			path:         "/readyz",
+			wantServing:  http.StatusNotFound,
+			wantNotReady: http.StatusNotFound,
+			wantRejected: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := NewServer("test", "localhost:0", tc.opts...)
+			srv.Handle("/hello", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			get := func(path string) int {
+				recorder := httptest.NewRecorder()
+				srv.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+				return recorder.Code
+			}
+
+			assert.Equal(t, tc.wantServing, get(tc.path))
+
+			// 撤销就绪状态之后，依旧处理普通请求
+			srv.MarkNotReady()
+			assert.Equal(t, tc.wantNotReady, get(tc.path))
+			assert.Equal(t, http.StatusOK, get("/hello"))
+
+			srv.RejectNew()
+			assert.Equal(t, tc.wantRejected, get(tc.path))
+			assert.Equal(t, http.StatusServiceUnavailable, get("/hello"))
+		})
+	}
+}
+
+func TestServer_MaxInflight(t *testing.T) {
+	const n = 3
+	release := make(chan struct{})
+	srv := NewServer("test", "localhost:0", ServerWithMaxInflight(n))
+	srv.Handle("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	results := make(chan *httptest.ResponseRecorder, n+1)
+	for i := 0; i < n+1; i++ {
+		go func() {
+			recorder := httptest.NewRecorder()
+			srv.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			results <- recorder
+		}()
+	}
+	// 被拒绝的请求不会等待，所以一定是第一个返回的
+	rejected := <-results
+	assert.Equal(t, http.StatusServiceUnavailable, rejected.Code)
+	assert.Equal(t, "服务繁忙", rejected.Body.String())
+	assert.Equal(t, "1", rejected.Header().Get("Retry-After"))
+	select {
+	case <-results:
+		t.Fatal("超过限制的请求只有一个")
+	case <-time.After(time.Millisecond * 50):
+	}
+	// 就绪检查不受限制
+	ready := httptest.NewRecorder()
+	srv.mux.ServeHTTP(ready, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, ready.Code)
+
+	close(release)
+	for i := 0; i < n; i++ {
+		assert.Equal(t, http.StatusOK, (<-results).Code)
+	}
+	assert.Equal(t, int64(0), atomic.LoadInt64(&srv.mux.inflight))
+
+	// 请求结束之后又可以处理新请求了
+	recorder := httptest.NewRecorder()
+	srv.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	// 关闭之后的拒绝和超过限制的拒绝是不同的
+	srv.RejectNew()
+	recorder = httptest.NewRecorder()
+	srv.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "服务已关闭", recorder.Body.String())
+	assert.Empty(t, recorder.Header().Get("Retry-After"))
+}
+
+func TestServer_WaitInflight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	srv := NewServer("test", "localhost:0", ServerWithMaxInflight(1))
+	srv.Handle("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go srv.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+	// 被限制拒绝的请求不会影响等待
+	recorder := httptest.NewRecorder()
+	srv.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, "服务繁忙", recorder.Body.String())
+
+	srv.RejectNew()
+	done := make(chan struct{})
+	go func() {
+		srv.WaitInflight(time.Minute)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("请求还没有结束")
+	case <-time.After(time.Millisecond * 20):
+	}
+	// 限制和等待使用的是同一个计数，请求结束之后马上就不用等了
+	close(release)
+	<-done
+	assert.Equal(t, int64(0), atomic.LoadInt64(&srv.mux.inflight))
+}
+
+func TestApp_ConcurrentWaitInflight(t *testing.T) {
+	var (
+		arrived  sync.WaitGroup
+		timedOut int32
+	)
+	servers := make([]Shutdownable, 0, 2)
+	for i := 0; i < 2; i++ {
+		arrived.Add(1)
+		servers = append(servers, rendezvousServer{
+			mockServer: newMockServer(func() {}),
+			arrived:    &arrived,
+			timedOut:   &timedOut,
+		})
+	}
+	app := NewApp(nil, WithServers(servers...), WithWaitTime(time.Second*5))
+	app.shutdown()
+	// 所有服务器同时等待，所以都等到了其它服务器，没有一个超时
+	assert.Equal(t, int32(0), atomic.LoadInt32(&timedOut))
+}
+
+func TestApp_stopServers(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	servers := make([]*Server, 0, 2)
+	var started sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		srv := NewServer(fmt.Sprintf("server-%d", i), "localhost:0")
+		srv.Handle("/stuck", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started.Done()
+			<-release
+		}))
+		ln, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		go func() {
+			_ = srv.srv.Serve(ln)
+		}()
+		started.Add(1)
+		go func() {
+			resp, err := http.Get("http://" + ln.Addr().String() + "/stuck")
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+		servers = append(servers, srv)
+	}
+	started.Wait()
+
+	// 请求一直没有结束，所以服务器一直关不掉，只能等到超时之后强制关闭
+	app := NewApp(servers)
+	timeout := time.Millisecond * 100
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	assert.Equal(t, []error{context.DeadlineExceeded, context.DeadlineExceeded}, app.stopServers(ctx))
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, timeout)
+	// 超时之后马上强制关闭，不会一直等下去。上限宽松一些，避免机器负载高的时候失败
+	assert.Less(t, elapsed, timeout*10)
+}
+
+func TestServer_stop(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr error
+	}{
+		{
+			name: "graceful",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+		},
+		{
+			// 一直不返回的请求，超时之后强制关闭
+			name: "hanging",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := NewServer("test", "localhost:0")
+			started := make(chan struct{})
+			srv.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				tc.handler(w, r)
+			}))
+			ln, err := net.Listen("tcp", "localhost:0")
+			require.NoError(t, err)
+			serveErr := make(chan error, 1)
+			go func() {
+				serveErr <- srv.srv.Serve(ln)
+			}()
+			reqErr := make(chan error, 1)
+			go func() {
+				resp, err := http.Get("http://" + ln.Addr().String() + "/")
+				if err == nil {
+					_ = resp.Body.Close()
+				}
+				reqErr <- err
+			}()
+			<-started
+
+			timeout := time.Millisecond * 100
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			start := time.Now()
+			assert.Equal(t, tc.wantErr, srv.Stop(ctx))
+			// 上限宽松一些，避免机器负载高的时候失败
+			assert.Less(t, time.Since(start), timeout*10)
+			assert.Equal(t, http.ErrServerClosed, <-serveErr)
+			// 强制关闭之后，客户端的连接被断开
+			if tc.wantErr != nil {
+				assert.Error(t, <-reqErr)
+			} else {
+				assert.NoError(t, <-reqErr)
+			}
+		})
+	}
+}
+
+// mockServer 记录 App 调用各个方法的顺序
+type mockServer struct {
+	mutex  sync.Mutex
+	events []string
+	// onStart 在 Start 的时候调用
+	onStart func()
+	stopped chan struct{}
+}
+
+func newMockServer(onStart func()) *mockServer {
+	return &mockServer{
+		onStart: onStart,
+		stopped: make(chan struct{}),
+	}
+}
+
+func (m *mockServer) record(event string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.events = append(m.events, event)
+}
+
+func (m *mockServer) Start() error {
+	m.record("start")
+	m.onStart()
+	<-m.stopped
+	return nil
+}
+
+func (m *mockServer) MarkNotReady() {
+	m.record("not-ready")
+}
+
+func (m *mockServer) RejectNew() {
+	m.record("reject")
+}
+
+func (m *mockServer) WaitInflight(timeout time.Duration) {
+	m.record("drain")
+}
+
+func (m *mockServer) Stop(ctx context.Context) error {
+	m.record("stop")
+	close(m.stopped)
+	return nil
+}
+
+// rendezvousServer 的 WaitInflight 要等到所有服务器都开始等待才会返回，
+// 如果 App 是一个接一个等待的，那么第一个服务器会等到超时
+type rendezvousServer struct {
+	*mockServer
+	arrived  *sync.WaitGroup
+	timedOut *int32
+}
+
+func (r rendezvousServer) WaitInflight(timeout time.Duration) {
+	r.arrived.Done()
+	ch := make(chan struct{})
+	go func() {
+		r.arrived.Wait()
+		close(ch)
+	}()
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+		atomic.AddInt32(r.timedOut, 1)
+	}
+}
+
+func TestApp_Shutdownable(t *testing.T) {
+	var app *App
+	srv := newMockServer(func() {
+		// 启动之后再发信号，保证 start 一定在最前面
+		app.signals <- syscall.SIGTERM
+	})
+	app = NewApp(nil, WithServers(srv),
+		WithShutdownCallbacks(func(ctx context.Context) {
+			srv.record("callback")
+		}))
+	require.NoError(t, app.StartAndServe())
+
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	assert.Equal(t, []string{"start", "not-ready", "reject", "drain", "stop", "callback"}, srv.events)
+}
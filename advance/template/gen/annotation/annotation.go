@@ -3,6 +3,8 @@ package annotation
 import (
 	"go/ast"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Annotations[NN ast.Node] struct {
@@ -19,32 +21,77 @@ func (a Annotations[NN]) Get(key string) (Annotation, bool) {
 	return Annotation{}, false
 }
 
+// GetAll 返回所有 key 匹配的注解，按照在源码中出现的顺序排列，
+// 例如同一个方法上面的多个 @param
+func (a Annotations[NN]) GetAll(key string) []Annotation {
+	var res []Annotation
+	for _, an := range a.Ans {
+		if an.Key == key {
+			res = append(res, an)
+		}
+	}
+	return res
+}
+
 type Annotation struct {
 	Key   string
 	Value string
 }
 
-func newAnnotations[NN ast.Node](n NN, cg *ast.CommentGroup) Annotations[NN] {
+// 分隔注解 key 和 value 的字符
+const (
+	SeparatorSpace = ' '
+	SeparatorEqual = '='
+	SeparatorColon = ':'
+)
+
+// Option 用于配置注解的解析
+type Option func(c *config)
+
+type config struct {
+	seps string
+}
+
+func newConfig(opts []Option) config {
+	c := config{seps: string(SeparatorSpace)}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithSeparators 指定分隔 key 和 value 的字符，默认只有空格。
+// 可以同时指定多个，例如 WithSeparators(SeparatorSpace, SeparatorEqual)
+// 之后 @key value 和 @key=value 都能解析
+func WithSeparators(seps ...rune) Option {
+	return func(c *config) {
+		if len(seps) > 0 {
+			c.seps = string(seps)
+		}
+	}
+}
+
+// newAnnotations 解析 cg 里面的注解。
+// 以 @ 开头的行是注解，@ 之后到第一个分隔符之前是 key，分隔符之后的部分是 value，
+// 没有分隔符的话整行都是 key，value 为空字符串。
+// 默认的分隔符是空格，所以 @key:subkey value 的 key 是 key:subkey；
+// 分隔符不是空格的时候，key 和 value 前后的空白字符会被去掉，例如 @key = value
+func newAnnotations[NN ast.Node](n NN, cg *ast.CommentGroup, opts ...Option) Annotations[NN] {
 	if cg == nil || len(cg.List) == 0 {
 		return Annotations[NN]{Node: n}
 	}
+	c := newConfig(opts)
 	ans := make([]Annotation, 0, len(cg.List))
-	for _, c := range cg.List {
-		text, ok := extractContent(c)
+	for _, cm := range cg.List {
+		lines, ok := extractContent(cm)
 		if !ok {
 			continue
 		}
-		if strings.HasPrefix(text, "@") {
-			segs := strings.SplitN(text, " ", 2)
-			key := segs[0][1:]
-			val := ""
-			if len(segs) == 2 {
-				val = segs[1]
+		for _, text := range lines {
+			if !strings.HasPrefix(text, "@") {
+				continue
 			}
-			ans = append(ans, Annotation{
-				Key:   key,
-				Value: val,
-			})
+			ans = append(ans, c.parse(text[1:]))
 		}
 	}
 	return Annotations[NN]{
@@ -53,13 +100,100 @@ func newAnnotations[NN ast.Node](n NN, cg *ast.CommentGroup) Annotations[NN] {
 	}
 }
 
-func extractContent(c *ast.Comment) (string, bool) {
+// parse 解析去掉了 @ 的注解
+func (c config) parse(text string) Annotation {
+	idx := strings.IndexAny(text, c.seps)
+	if idx < 0 {
+		return Annotation{Key: text}
+	}
+	key, val := text[:idx], text[idx+1:]
+	if text[idx] != SeparatorSpace {
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+	}
+	return Annotation{
+		Key:   key,
+		Value: val,
+	}
+}
+
+// extractContent 提取注释的内容，按照逻辑行返回。
+// 去掉注释符号之后，行首的空白字符也会被去掉，所以 //@key、// @key 和 //   @key 都是注解。
+// 但是 // 后面紧跟着的不是空白字符也不是 @ 的话，例如 //go:generate 和 //nolint，
+// 那么它是编译器或者工具的指令，不是注解。
+// 行注释 // 只有一行；块注释 /* */ 里面每一个以 @ 开头的行都是一个新的注解，
+// 所以一个块注释里面可以写多个注解，具体的规则见 blockLines。
+// 注解以反斜杠 \ 结尾的时候会和下一行拼接起来，并且去掉下一行首尾的空白字符，例如：
+//
+//	/* @sql SELECT * \
+//	   FROM t */
+//
+// 得到的注解值是 "SELECT *\nFROM t"
+func extractContent(c *ast.Comment) ([]string, bool) {
 	text := c.Text
-	if strings.HasPrefix(text, "// ") {
-		return text[3:], true
-	} else if strings.HasPrefix(text, "/* ") {
+	if strings.HasPrefix(text, "//") {
+		text = text[2:]
+		if isDirective(text) {
+			return nil, false
+		}
+		return []string{strings.TrimLeft(text, " \t")}, true
+	} else if strings.HasPrefix(text, "/*") {
 		length := len(text)
-		return text[3 : length-2], true
+		return blockLines(text[2 : length-2]), true
+	}
+	return nil, false
+}
+
+// isDirective 判断去掉了 // 的行注释是不是指令
+func isDirective(text string) bool {
+	if text == "" {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(text)
+	return r != '@' && !unicode.IsSpace(r)
+}
+
+// continuation 是块注释里面的续行符
+const continuation = `\`
+
+// blockLines 将块注释的内容切割成逻辑行，规则是：
+//   - 去掉首尾空白字符之后以 @ 开头的行是一个新的注解，首尾的空白字符会被去掉；
+//   - 注解的行以续行符 \ 结尾的时候，去掉续行符，并且和去掉首尾空白字符的下一行用换行符拼接起来；
+//   - 其它跟在注解后面的行原样拼接到注解后面，中间用换行符连接，包括最后 */ 之前的空白；
+//   - 第一个注解之前的行各自是一行，它们不是注解。
+func blockLines(text string) []string {
+	rawLines := strings.Split(text, "\n")
+	res := make([]string, 0, len(rawLines))
+	var sb strings.Builder
+	// inAnnotation 说明 sb 里面是一个还没有结束的注解
+	inAnnotation := false
+	joining := false
+	for _, raw := range rawLines {
+		line := strings.TrimSpace(raw)
+		switch {
+		case joining:
+			sb.WriteByte('\n')
+		case strings.HasPrefix(line, "@"):
+			if inAnnotation {
+				res = append(res, sb.String())
+				sb.Reset()
+			}
+			inAnnotation = true
+		case inAnnotation:
+			sb.WriteByte('\n')
+			sb.WriteString(raw)
+			continue
+		default:
+			res = append(res, line)
+			continue
+		}
+		joining = strings.HasSuffix(line, continuation)
+		if joining {
+			line = strings.TrimRight(strings.TrimSuffix(line, continuation), " \t")
+		}
+		sb.WriteString(line)
+	}
+	if inAnnotation {
+		res = append(res, sb.String())
 	}
-	return "", false
+	return res
 }
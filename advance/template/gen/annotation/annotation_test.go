@@ -0,0 +1,319 @@
+package annotation
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestAnnotations_GetAll(t *testing.T) {
+	src := `
+package annotation
+
+// Create 创建用户
+// @param name string
+// @return error
+// @param age int
+// @param email string
+func Create(name string, age int, email string) error
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := f.Decls[0].(*ast.FuncDecl)
+	ans := newAnnotations(fd, fd.Doc)
+
+	testCases := []struct {
+		name string
+		key  string
+		want []Annotation
+	}{
+		{
+			name: "multiple",
+			key:  "param",
+			want: []Annotation{
+				{Key: "param", Value: "name string"},
+				{Key: "param", Value: "age int"},
+				{Key: "param", Value: "email string"},
+			},
+		},
+		{
+			name: "single",
+			key:  "return",
+			want: []Annotation{
+				{Key: "return", Value: "error"},
+			},
+		},
+		{
+			name: "not found",
+			key:  "author",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ans.GetAll(tc.key))
+		})
+	}
+}
+
+func TestNewAnnotations_BlockComment(t *testing.T) {
+	testCases := []struct {
+		name    string
+		comment string
+		want    []Annotation
+	}{
+		{
+			name: "two lines",
+			comment: `/* @sql SELECT * \
+   FROM t */`,
+			want: []Annotation{
+				{Key: "sql", Value: "SELECT *\nFROM t"},
+			},
+		},
+		{
+			name: "three lines",
+			comment: `/* @sql SELECT * \
+	FROM t \
+	WHERE id = ? */`,
+			want: []Annotation{
+				{Key: "sql", Value: "SELECT *\nFROM t\nWHERE id = ?"},
+			},
+		},
+		{
+			// */ 之前的换行和其它没有续行符的行一样，原样保留
+			name: "close on next line",
+			comment: `/* @sql SELECT * \
+   FROM t
+*/`,
+			want: []Annotation{
+				{Key: "sql", Value: "SELECT *\nFROM t\n"},
+			},
+		},
+		{
+			// 没有续行符的行原样拼接到注解后面
+			name:    "without continuation",
+			comment: "/* @multiple first line\n\t   second line\n\t*/",
+			want: []Annotation{
+				{Key: "multiple", Value: "first line\n\t   second line\n\t"},
+			},
+		},
+		{
+			name:    "continuation and raw line",
+			comment: "/* @multiple first line \\\n\t   second line\n\t*/",
+			want: []Annotation{
+				{Key: "multiple", Value: "first line\nsecond line\n\t"},
+			},
+		},
+		{
+			// 以 @ 开头的行是新的注解
+			name: "multiple annotations",
+			comment: `/* @author Deng Ming
+   @date 2022/04/02 */`,
+			want: []Annotation{
+				{Key: "author", Value: "Deng Ming"},
+				{Key: "date", Value: "2022/04/02"},
+			},
+		},
+		{
+			name: "text before annotation",
+			comment: `/* not an annotation
+   @author Deng Ming */`,
+			want: []Annotation{
+				{Key: "author", Value: "Deng Ming"},
+			},
+		},
+		{
+			name:    "single line",
+			comment: `/* @author Deng Ming */`,
+			want: []Annotation{
+				{Key: "author", Value: "Deng Ming"},
+			},
+		},
+		{
+			name: "continuation at end",
+			comment: `/* @sql SELECT * \
+*/`,
+			want: []Annotation{
+				{Key: "sql", Value: "SELECT *\n"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := &ast.CommentGroup{List: []*ast.Comment{{Text: tc.comment}}}
+			ans := newAnnotations[ast.Node](nil, cg)
+			assert.Equal(t, tc.want, ans.Ans)
+		})
+	}
+}
+
+func TestNewAnnotations_Separator(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    []Option
+		comment string
+		want    Annotation
+	}{
+		{
+			name:    "default space",
+			comment: "// @author foo@bar.com",
+			want:    Annotation{Key: "author", Value: "foo@bar.com"},
+		},
+		{
+			name:    "default key only",
+			comment: "// @HttpClient",
+			want:    Annotation{Key: "HttpClient"},
+		},
+		{
+			// 默认只用空格分隔，所以冒号是 key 的一部分
+			name:    "default colon in key",
+			comment: "// @key:subkey value",
+			want:    Annotation{Key: "key:subkey", Value: "value"},
+		},
+		{
+			name:    "equal",
+			opts:    []Option{WithSeparators(SeparatorEqual)},
+			comment: "// @path=/user/update",
+			want:    Annotation{Key: "path", Value: "/user/update"},
+		},
+		{
+			name:    "equal with spaces",
+			opts:    []Option{WithSeparators(SeparatorEqual)},
+			comment: "// @path = /user/update",
+			want:    Annotation{Key: "path", Value: "/user/update"},
+		},
+		{
+			// 只有第一个分隔符生效
+			name:    "equal in value",
+			opts:    []Option{WithSeparators(SeparatorEqual)},
+			comment: "// @default=a=b",
+			want:    Annotation{Key: "default", Value: "a=b"},
+		},
+		{
+			name:    "colon",
+			opts:    []Option{WithSeparators(SeparatorColon)},
+			comment: "// @key:subkey value",
+			want:    Annotation{Key: "key", Value: "subkey value"},
+		},
+		{
+			name:    "space or equal with space",
+			opts:    []Option{WithSeparators(SeparatorSpace, SeparatorEqual)},
+			comment: "// @path /user/update",
+			want:    Annotation{Key: "path", Value: "/user/update"},
+		},
+		{
+			name:    "space or equal with equal",
+			opts:    []Option{WithSeparators(SeparatorSpace, SeparatorEqual)},
+			comment: "// @path=/user/update",
+			want:    Annotation{Key: "path", Value: "/user/update"},
+		},
+		{
+			// 没有指定分隔符的时候使用默认的空格
+			name:    "empty separators",
+			opts:    []Option{WithSeparators()},
+			comment: "// @path /user/update",
+			want:    Annotation{Key: "path", Value: "/user/update"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := &ast.CommentGroup{List: []*ast.Comment{{Text: tc.comment}}}
+			ans := newAnnotations[ast.Node](nil, cg, tc.opts...)
+			assert.Equal(t, []Annotation{tc.want}, ans.Ans)
+		})
+	}
+}
+
+func TestExtractContent(t *testing.T) {
+	testCases := []struct {
+		name    string
+		comment string
+		want    []string
+		wantOk  bool
+	}{
+		{
+			name:    "without space",
+			comment: "//@x value",
+			want:    []string{"@x value"},
+			wantOk:  true,
+		},
+		{
+			name:    "with space",
+			comment: "// @x value",
+			want:    []string{"@x value"},
+			wantOk:  true,
+		},
+		{
+			name:    "indented",
+			comment: "//  \t@x value",
+			want:    []string{"@x value"},
+			wantOk:  true,
+		},
+		{
+			name:    "plain text",
+			comment: "// hello world",
+			want:    []string{"hello world"},
+			wantOk:  true,
+		},
+		{
+			name:    "empty",
+			comment: "//",
+			want:    []string{""},
+			wantOk:  true,
+		},
+		{
+			name:    "go generate",
+			comment: "//go:generate stringer -type=Status",
+		},
+		{
+			name:    "nolint",
+			comment: "//nolint:@all",
+		},
+		{
+			name:    "block without space",
+			comment: "/*@x value*/",
+			want:    []string{"@x value"},
+			wantOk:  true,
+		},
+		{
+			name:    "block indented",
+			comment: "/*   @x value */",
+			want:    []string{"@x value"},
+			wantOk:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, ok := extractContent(&ast.Comment{Text: tc.comment})
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestNewAnnotations_Directive(t *testing.T) {
+	src := `
+package annotation
+
+// Status 状态
+//go:generate stringer -type=Status
+//@enum active=1 inactive=2
+//   @author Deng Ming
+type Status uint8
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gd := f.Decls[0].(*ast.GenDecl)
+	ans := newAnnotations(gd.Specs[0].(*ast.TypeSpec), gd.Doc)
+	assert.Equal(t, []Annotation{
+		{Key: "enum", Value: "active=1 inactive=2"},
+		{Key: "author", Value: "Deng Ming"},
+	}, ans.Ans)
+}
@@ -0,0 +1,33 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyEnum 枚举注解，例如 // @enum active=1 inactive=2
+const KeyEnum = "enum"
+
+// EnumValue 枚举注解里面的一个枚举值
+type EnumValue struct {
+	Name  string
+	Value string
+}
+
+// EnumValues 将注解的值解析为枚举值，按照注解里面的顺序返回。
+// 枚举值之间用空格分隔，每一个都必须是 name=value 的形式
+func (a Annotation) EnumValues() ([]EnumValue, error) {
+	pairs := strings.Fields(a.Value)
+	res := make([]EnumValue, 0, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("annotation: 非法的枚举值 %s", pair)
+		}
+		res = append(res, EnumValue{
+			Name:  kv[0],
+			Value: kv[1],
+		})
+	}
+	return res, nil
+}
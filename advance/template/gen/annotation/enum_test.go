@@ -0,0 +1,92 @@
+package annotation
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestAnnotation_EnumValues(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    []EnumValue
+		wantErr error
+	}{
+		{
+			name:  "multiple values",
+			value: "active=1 inactive=2 deleted=3",
+			want: []EnumValue{
+				{Name: "active", Value: "1"},
+				{Name: "inactive", Value: "2"},
+				{Name: "deleted", Value: "3"},
+			},
+		},
+		{
+			name:  "extra spaces",
+			value: "  active=1   inactive=2 ",
+			want: []EnumValue{
+				{Name: "active", Value: "1"},
+				{Name: "inactive", Value: "2"},
+			},
+		},
+		{
+			name:  "empty",
+			value: "",
+			want:  []EnumValue{},
+		},
+		{
+			name:    "missing value",
+			value:   "active=1 inactive",
+			wantErr: errors.New("annotation: 非法的枚举值 inactive"),
+		},
+		{
+			name:    "empty name",
+			value:   "=1",
+			wantErr: errors.New("annotation: 非法的枚举值 =1"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			an := Annotation{Key: KeyEnum, Value: tc.value}
+			res, err := an.EnumValues()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestAnnotation_EnumValuesFromSource(t *testing.T) {
+	src := `
+package annotation
+
+// Status 状态
+// @enum active=1 inactive=2
+type Status uint8
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gd := f.Decls[0].(*ast.GenDecl)
+	ans := newAnnotations(gd.Specs[0].(*ast.TypeSpec), gd.Doc)
+	an, ok := ans.Get(KeyEnum)
+	if !ok {
+		t.Fatal("没有找到枚举注解")
+	}
+	res, err := an.EnumValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []EnumValue{
+		{Name: "active", Value: "1"},
+		{Name: "inactive", Value: "2"},
+	}, res)
+}
@@ -2,11 +2,31 @@ package annotation
 
 import (
 	"go/ast"
+	"go/parser"
+	"go/token"
 )
 
+// ParseFile 解析 path 对应的 Go 源文件，收集包、类型、字段和函数上的注解
+func ParseFile(path string, opts ...Option) (File, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return File{}, err
+	}
+	tv := NewSingleFileEntryVisitor(opts...)
+	ast.Walk(tv, f)
+	return tv.Get(), nil
+}
+
 // SingleFileEntryVisitor 这部分和课堂演示差不多，但是我建议你们自己试着写一些
 type SingleFileEntryVisitor struct {
 	file *fileVisitor
+	opts []Option
+}
+
+// NewSingleFileEntryVisitor 创建 SingleFileEntryVisitor，opts 用于配置注解的解析
+func NewSingleFileEntryVisitor(opts ...Option) *SingleFileEntryVisitor {
+	return &SingleFileEntryVisitor{opts: opts}
 }
 
 func (s *SingleFileEntryVisitor) Get() File {
@@ -20,8 +40,10 @@ func (s *SingleFileEntryVisitor) Visit(node ast.Node) ast.Visitor {
 	file, ok := node.(*ast.File)
 	if ok {
 		s.file = &fileVisitor{
-			ans: newAnnotations(file, file.Doc),
+			ans:  newAnnotations(file, file.Doc, s.opts...),
+			opts: s.opts,
 		}
+		return s.file
 	}
 	return nil
 }
@@ -29,7 +51,9 @@ func (s *SingleFileEntryVisitor) Visit(node ast.Node) ast.Visitor {
 type fileVisitor struct {
 	ans     Annotations[*ast.File]
 	types   []*typeVisitor
+	funcs   []Func
 	visited bool
+	opts    []Option
 }
 
 func (f *fileVisitor) Get() File {
@@ -40,18 +64,36 @@ func (f *fileVisitor) Get() File {
 	return File{
 		Annotations: f.ans,
 		Types:       types,
+		Funcs:       f.funcs,
 	}
 }
 
 func (f *fileVisitor) Visit(node ast.Node) ast.Visitor {
-	typ, ok := node.(*ast.TypeSpec)
-	if ok {
-		res := &typeVisitor{
-			ans:    newAnnotations(typ, typ.Doc),
-			fields: make([]Field, 0, 0),
+	switch n := node.(type) {
+	case *ast.GenDecl:
+		if n.Tok != token.TYPE {
+			return nil
 		}
-		f.types = append(f.types, res)
-		return res
+		for _, spec := range n.Specs {
+			typ := spec.(*ast.TypeSpec)
+			doc := typ.Doc
+			// type A struct{} 这种没有括号的写法，注释是在 GenDecl 上面的
+			if doc == nil && !n.Lparen.IsValid() {
+				doc = n.Doc
+			}
+			res := &typeVisitor{
+				ans:    newAnnotations(typ, doc, f.opts...),
+				fields: make([]Field, 0, 0),
+				opts:   f.opts,
+			}
+			f.types = append(f.types, res)
+			ast.Walk(res, typ)
+		}
+		return nil
+	case *ast.FuncDecl:
+		// 函数和方法都在这里，方法可以通过 Node.Recv 区分。不需要进去函数体里面
+		f.funcs = append(f.funcs, Func{Annotations: newAnnotations(n, n.Doc, f.opts...)})
+		return nil
 	}
 	return f
 }
@@ -59,11 +101,13 @@ func (f *fileVisitor) Visit(node ast.Node) ast.Visitor {
 type File struct {
 	Annotations[*ast.File]
 	Types []Type
+	Funcs []Func
 }
 
 type typeVisitor struct {
 	ans    Annotations[*ast.TypeSpec]
 	fields []Field
+	opts   []Option
 }
 
 func (t *typeVisitor) Get() Type {
@@ -76,7 +120,7 @@ func (t *typeVisitor) Get() Type {
 func (t *typeVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	fd, ok := node.(*ast.Field)
 	if ok {
-		t.fields = append(t.fields, Field{Annotations: newAnnotations(fd, fd.Doc)})
+		t.fields = append(t.fields, Field{Annotations: newAnnotations(fd, fd.Doc, t.opts...)})
 		return nil
 	}
 	return t
@@ -90,3 +134,7 @@ type Type struct {
 type Field struct {
 	Annotations[*ast.Field]
 }
+
+type Func struct {
+	Annotations[*ast.FuncDecl]
+}
@@ -2,6 +2,7 @@ package annotation
 
 import (
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go/ast"
 	"go/parser"
 	"go/token"
@@ -251,3 +252,44 @@ func assertAnnotations[N ast.Node](t *testing.T, wantAns Annotations[N], dst Ann
 		assert.Equal(t, an.Value, val.Value)
 	}
 }
+
+func TestParseFile(t *testing.T) {
+	file, err := ParseFile("testdata/user.go")
+	require.NoError(t, err)
+	assert.Equal(t, "testdata", file.Node.Name.Name)
+	assert.Equal(t, []Annotation{{Key: "author", Value: "Deng Ming"}}, file.Ans)
+
+	require.Len(t, file.Types, 2)
+	user := file.Types[0]
+	assert.Equal(t, "User", user.Node.Name.Name)
+	assert.Equal(t, []Annotation{{Key: "table", Value: "user"}}, user.Ans)
+	require.Len(t, user.Fields, 3)
+	assert.Equal(t, []Annotation{{Key: "column", Value: "name=id,primary_key"}}, user.Fields[0].Ans)
+	assert.Equal(t, []Annotation{{Key: "column", Value: "name=user_name"}}, user.Fields[1].Ans)
+	assert.Empty(t, user.Fields[2].Ans)
+
+	order := file.Types[1]
+	assert.Equal(t, "Order", order.Node.Name.Name)
+	assert.Equal(t, []Annotation{{Key: "table", Value: "order"}}, order.Ans)
+	require.Len(t, order.Fields, 1)
+	assert.Empty(t, order.Fields[0].Ans)
+
+	require.Len(t, file.Funcs, 2)
+	assert.Equal(t, "NewUser", file.Funcs[0].Node.Name.Name)
+	assert.Equal(t, []Annotation{{Key: "param", Value: "name string"}}, file.Funcs[0].Ans)
+	assert.Equal(t, "TableName", file.Funcs[1].Node.Name.Name)
+	assert.NotNil(t, file.Funcs[1].Node.Recv)
+	assert.Equal(t, []Annotation{{Key: "return", Value: "string"}}, file.Funcs[1].Ans)
+
+	_, err = ParseFile("testdata/not_exist.go")
+	assert.Error(t, err)
+}
+
+func TestParseFile_Separator(t *testing.T) {
+	file, err := ParseFile("testdata/user.go", WithSeparators(SeparatorEqual))
+	require.NoError(t, err)
+	// 只用 = 分隔，空格是 key 的一部分
+	assert.Equal(t, []Annotation{{Key: "author Deng Ming"}}, file.Ans)
+	assert.Equal(t, []Annotation{{Key: "column name", Value: "id,primary_key"}}, file.Types[0].Fields[0].Ans)
+	assert.Equal(t, []Annotation{{Key: "param name string"}}, file.Funcs[0].Ans)
+}
@@ -0,0 +1,64 @@
+package annotation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Params 将注解的值解析为逗号分隔的 key=value，例如 // @column name=user_id,nullable=true
+//   - key 和 value 前后的空白字符会被去掉
+//   - value 可以用双引号括起来，这样就可以包含逗号和空格，例如 desc="hello, world"，
+//     双引号里面用 \" 表示双引号本身，\\ 表示反斜杠
+//   - 没有 = 的部分被看做是值为空字符串的 key，例如 primary_key
+//   - 空的部分会被忽略，所以末尾多出来的逗号没有影响
+//
+// 同一个 key 出现多次的时候，后面的覆盖前面的
+func (a Annotation) Params() map[string]string {
+	res := make(map[string]string, 4)
+	var (
+		key, val strings.Builder
+		cur      = &key
+		quoted   bool
+	)
+	flush := func() {
+		if k := strings.TrimSpace(key.String()); k != "" {
+			v := val.String()
+			if !quoted {
+				v = strings.TrimSpace(v)
+			}
+			res[k] = v
+		}
+		key.Reset()
+		val.Reset()
+		cur = &key
+		quoted = false
+	}
+	rs := []rune(a.Value)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '"':
+			if cur == &val {
+				// 引号前面只可能是空白字符，丢弃
+				val.Reset()
+				quoted = true
+			}
+			for i++; i < len(rs) && rs[i] != '"'; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				cur.WriteRune(rs[i])
+			}
+		case r == '=' && cur == &key:
+			cur = &val
+		case r == ',':
+			flush()
+		case quoted && unicode.IsSpace(r):
+			// 引号后面的空白字符
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return res
+}
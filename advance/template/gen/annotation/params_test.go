@@ -0,0 +1,103 @@
+package annotation
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAnnotation_Params(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value string
+		want  map[string]string
+	}{
+		{
+			name:  "multiple params",
+			value: "name=user_id,nullable=true",
+			want: map[string]string{
+				"name":     "user_id",
+				"nullable": "true",
+			},
+		},
+		{
+			name:  "whitespace",
+			value: " name = user_id , nullable=true ",
+			want: map[string]string{
+				"name":     "user_id",
+				"nullable": "true",
+			},
+		},
+		{
+			name:  "quoted",
+			value: `name=user_id, desc = "hello, world" `,
+			want: map[string]string{
+				"name": "user_id",
+				"desc": "hello, world",
+			},
+		},
+		{
+			name:  "quoted with spaces",
+			value: `desc="  padded  "`,
+			want: map[string]string{
+				"desc": "  padded  ",
+			},
+		},
+		{
+			name:  "escaped quotes",
+			value: `desc="say \"hi\", then \\ leave",name=id`,
+			want: map[string]string{
+				"desc": `say "hi", then \ leave`,
+				"name": "id",
+			},
+		},
+		{
+			name:  "trailing commas",
+			value: "name=user_id,,nullable=true,",
+			want: map[string]string{
+				"name":     "user_id",
+				"nullable": "true",
+			},
+		},
+		{
+			name:  "without value",
+			value: "primary_key,name=id",
+			want: map[string]string{
+				"primary_key": "",
+				"name":        "id",
+			},
+		},
+		{
+			name:  "empty value",
+			value: "name=",
+			want: map[string]string{
+				"name": "",
+			},
+		},
+		{
+			name:  "value with equal sign",
+			value: "default=a=b",
+			want: map[string]string{
+				"default": "a=b",
+			},
+		},
+		{
+			// 没有闭合的引号，一直读到末尾
+			name:  "unterminated quote",
+			value: `desc="hello, world`,
+			want: map[string]string{
+				"desc": "hello, world",
+			},
+		},
+		{
+			name:  "empty",
+			value: "",
+			want:  map[string]string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			an := Annotation{Key: "column", Value: tc.value}
+			assert.Equal(t, tc.want, an.Params())
+		})
+	}
+}
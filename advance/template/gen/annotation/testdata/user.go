@@ -0,0 +1,36 @@
+// Package testdata 用于测试 ParseFile
+// @author Deng Ming
+package testdata
+
+// User 用户
+// @table user
+type User struct {
+	// Id 主键
+	// @column name=id,primary_key
+	Id int64
+	// Name 名字
+	// @column name=user_name
+	Name string
+	Age  int
+}
+
+type (
+	// Order 订单
+	// @table order
+	Order struct {
+		Id int64
+	}
+)
+
+// NewUser 创建用户
+// @param name string
+func NewUser(name string) *User {
+	// @ignored 函数体里面的注释不会被收集
+	return &User{Name: name}
+}
+
+// TableName 方法
+// @return string
+func (u *User) TableName() string {
+	return "user"
+}
@@ -5,6 +5,8 @@ type Aggregate struct {
 	fn    string
 	arg   string
 	alias string
+	// distinct 为 true 的时候生成 COUNT(DISTINCT `id`) 这种形式
+	distinct bool
 }
 
 func (a Aggregate) selectable() {}
@@ -13,32 +15,55 @@ func (a Aggregate) expr() {}
 
 func (a Aggregate) As(alias string) Aggregate {
 	return Aggregate{
-		fn:    a.fn,
-		arg:   a.arg,
-		alias: alias,
+		fn:       a.fn,
+		arg:      a.arg,
+		alias:    alias,
+		distinct: a.distinct,
 	}
 }
 
-// EQ 例如 C("id").Eq(12)
-func (a Aggregate) EQ(arg any) Predicate {
-	return Predicate{
-		left: Aggregate{
-			fn:  a.fn,
-			arg: underscoreName(a.arg),
-		},
-		op: opEQ,
-		right: value{
-			val: arg,
-		},
+// Distinct 例如 Count("Id").Distinct()，生成 COUNT(DISTINCT `id`)
+func (a Aggregate) Distinct() Aggregate {
+	return Aggregate{
+		fn:       a.fn,
+		arg:      a.arg,
+		alias:    a.alias,
+		distinct: true,
 	}
 }
 
+// EQ 例如 Count("Id").EQ(12)，一般用在 HAVING 里面。
+// 和 Column 一样，arg 可以是列、聚合函数或者子查询
+func (a Aggregate) EQ(arg any) Predicate {
+	return a.compare(opEQ, arg)
+}
+
+func (a Aggregate) NotEQ(arg any) Predicate {
+	return a.compare(opNEQ, arg)
+}
+
 func (a Aggregate) LT(arg any) Predicate {
-	panic("implement me")
+	return a.compare(opLT, arg)
+}
+
+func (a Aggregate) LTEQ(arg any) Predicate {
+	return a.compare(opLTEQ, arg)
 }
 
 func (a Aggregate) GT(arg any) Predicate {
-	panic("implement me")
+	return a.compare(opGT, arg)
+}
+
+func (a Aggregate) GTEQ(arg any) Predicate {
+	return a.compare(opGTEQ, arg)
+}
+
+func (a Aggregate) compare(o op, arg any) Predicate {
+	return Predicate{
+		left:  a,
+		op:    o,
+		right: exprOf(arg),
+	}
 }
 
 func Avg(c string) Aggregate {
@@ -75,3 +100,39 @@ func Sum(c string) Aggregate {
 		arg: c,
 	}
 }
+
+// CondAggregate 代表条件聚合，用于在一次查询里面统计满足不同条件的数量
+type CondAggregate struct {
+	cond Predicate
+	// filter 为 true 的时候使用 COUNT(*) FILTER (WHERE ...) 的形式，
+	// 否则使用 SUM(CASE WHEN ... THEN 1 ELSE 0 END) 的形式
+	filter bool
+	alias  string
+}
+
+func (c CondAggregate) selectable() {}
+
+func (c CondAggregate) As(alias string) CondAggregate {
+	return CondAggregate{
+		cond:   c.cond,
+		filter: c.filter,
+		alias:  alias,
+	}
+}
+
+// CountIf 统计满足条件的行数，生成 SUM(CASE WHEN ... THEN 1 ELSE 0 END)，
+// 所有的数据库都支持
+func CountIf(p Predicate) CondAggregate {
+	return CondAggregate{
+		cond: p,
+	}
+}
+
+// CountFilter 统计满足条件的行数，生成 COUNT(*) FILTER (WHERE ...)，
+// 目前只有 PostgreSQL 方言支持
+func CountFilter(p Predicate) CondAggregate {
+	return CondAggregate{
+		cond:   p,
+		filter: true,
+	}
+}
@@ -0,0 +1,25 @@
+package orm
+
+// Assignable 标记接口，
+// 实现该接口意味着可以用于赋值语句，
+// 用于在 UPDATE 和 UPSERT 中
+type Assignable interface {
+	assign()
+}
+
+// Assignment 代表赋值语句，例如 `age`=?
+type Assignment struct {
+	column string
+	val    Expression
+}
+
+// Assign 创建一个赋值语句，column 是字段名。
+// val 可以是普通的值，也可以是 Expression，例如 C("Age")
+func Assign(column string, val any) Assignment {
+	return Assignment{
+		column: column,
+		val:    exprOf(val),
+	}
+}
+
+func (a Assignment) assign() {}
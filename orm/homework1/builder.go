@@ -0,0 +1,475 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+	"strings"
+)
+
+// builder 是各种语句构造器共享的部分
+type builder struct {
+	sb    strings.Builder
+	args  []any
+	model *model.Model
+	db    *DB
+	// sess 用于执行语句，可能是 DB，也可能是 Tx
+	sess Session
+	// argOffset 是在当前语句之前已经出现的参数个数，
+	// 作为子查询的时候用于计算带序号的占位符
+	argOffset int
+	// from 是 FROM 部分，如果它是子查询，那么没有指定表的列在子查询里面查找
+	from TableReference
+}
+
+func newBuilder(sess Session) builder {
+	return builder{
+		db:   sess.getDB(),
+		sess: sess,
+	}
+}
+
+// resolveModel 解析元数据，同一个构造器只会解析一次，
+// 所以多次调用 Build 或者 Build 之后再执行查询都不会重复解析
+func (b *builder) resolveModel(val any) error {
+	if b.model != nil {
+		return nil
+	}
+	m, err := b.db.r.Get(val)
+	if err != nil {
+		return err
+	}
+	b.model = m
+	return nil
+}
+
+// exec 经过中间件执行 INSERT, UPDATE 和 DELETE 语句
+func (b *builder) exec(ctx context.Context, typ string, q *Query) (Result, error) {
+	qc := &QueryContext{
+		Type:  typ,
+		Query: q,
+		Model: b.model,
+	}
+	res := b.db.handle(ctx, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		r, err := b.sess.execContext(ctx, qc.Query.SQL, qc.Query.Args...)
+		return &QueryResult{Result: r, Err: err}
+	})
+	r, _ := res.Result.(sql.Result)
+	return Result{res: r, err: res.Err}, res.Err
+}
+
+// toSQL 是各个构造器 ToSQL 的公共实现
+func toSQL(q *Query, err error) (string, []any, error) {
+	if err != nil {
+		return "", nil, err
+	}
+	return q.SQL, q.Args, nil
+}
+
+// queryErr 包装执行 q 的时候驱动返回的错误
+func (b *builder) queryErr(typ string, q *Query, err error) error {
+	return errs.NewErrQuery(typ, b.model.TableName, q.SQL, err)
+}
+
+// quote 使用方言的引号引用表名，列名和别名。
+// 如果设置了 DBWithNoQuoting，那么直接输出，但是名字必须是合法的标识符
+func (b *builder) quote(name string) error {
+	if b.db.noQuoting {
+		if !isIdentifier(name) {
+			return errs.NewErrInvalidIdentifier(name)
+		}
+		b.sb.WriteString(name)
+		return nil
+	}
+	q := b.db.dialect.quoter()
+	b.sb.WriteByte(q)
+	b.sb.WriteString(name)
+	b.sb.WriteByte(q)
+	return nil
+}
+
+// isIdentifier 只允许字母，数字和下划线，并且不能以数字开头
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (b *builder) buildColumn(c string, alias string) error {
+	return b.buildTableColumn(nil, c, alias)
+}
+
+// buildTableColumn 构造属于 table 的列。
+// 如果表有别名，那么使用别名作为前缀；如果是没有别名的 Table，那么使用表名作为前缀，
+// 这样在 JOIN 查询里面不会出现有歧义的列
+func (b *builder) buildTableColumn(table TableReference, c string, alias string) error {
+	// JOIN 查询里面没有指定表的列，加上它所属的表作为前缀
+	if j, ok := b.from.(Join); ok && table == nil {
+		var err error
+		if table, err = b.joinedTable(j, c); err != nil {
+			return err
+		}
+	}
+	if table != nil {
+		prefix := table.tableAlias()
+		if tbl, ok := table.(Table); ok && prefix == "" {
+			m, err := b.db.r.Get(tbl.entity)
+			if err != nil {
+				return err
+			}
+			prefix = m.TableName
+		}
+		if prefix != "" {
+			if err := b.quote(prefix); err != nil {
+				return err
+			}
+			b.sb.WriteByte('.')
+		}
+	}
+	colName, err := b.colName(table, c)
+	if err != nil {
+		return err
+	}
+	if err = b.quote(colName); err != nil {
+		return err
+	}
+	return b.buildAs(alias)
+}
+
+// colName 在 table 对应的模型里面查找列名。
+// table 为 nil 的时候使用当前语句的模型，FROM 部分是子查询的时候则在子查询里面找，
+// FROM 部分是 Join 的时候则在参与 JOIN 的表里面找；
+// 如果是 Join，那么先找左边，找不到再找右边
+func (b *builder) colName(table TableReference, c string) (string, error) {
+	if c == "" {
+		return "", errs.ErrEmptyColumn
+	}
+	switch tab := table.(type) {
+	case nil:
+		switch from := b.from.(type) {
+		case Subquery:
+			return b.colName(from, c)
+		case Join:
+			t, err := b.joinedTable(from, c)
+			if err != nil {
+				return "", err
+			}
+			return b.colName(t, c)
+		}
+		fd, ok := b.model.FieldMap[c]
+		if !ok {
+			return "", errs.NewErrUnknownField(c)
+		}
+		return fd.ColName, nil
+	case Table:
+		m, err := b.db.r.Get(tab.entity)
+		if err != nil {
+			return "", err
+		}
+		fd, ok := m.FieldMap[c]
+		if !ok {
+			return "", errs.NewErrUnknownField(c)
+		}
+		return fd.ColName, nil
+	case Join:
+		if colName, err := b.colName(tab.left, c); err == nil {
+			return colName, nil
+		}
+		return b.colName(tab.right, c)
+	case Subquery:
+		return b.subqueryColName(tab, c)
+	default:
+		return "", errs.NewErrUnsupportedTableReference(table)
+	}
+}
+
+// joinedTable 查找没有指定表的列属于 JOIN 里面的哪一个表。
+// 只有一个表有这个字段的时候才能确定，
+// 多个表都有的时候必须使用 TableOf[T]().C(...) 指定表
+func (b *builder) joinedTable(j Join, c string) (TableReference, error) {
+	tables := b.joinedTables(j, c, nil)
+	switch len(tables) {
+	case 0:
+		return nil, errs.NewErrUnknownField(c)
+	case 1:
+		return tables[0], nil
+	default:
+		return nil, errs.NewErrAmbiguousColumn(c)
+	}
+}
+
+// joinedTables 返回 JOIN 里面所有包含字段 c 的表
+func (b *builder) joinedTables(table TableReference, c string, res []TableReference) []TableReference {
+	switch tab := table.(type) {
+	case Join:
+		res = b.joinedTables(tab.left, c, res)
+		return b.joinedTables(tab.right, c, res)
+	case Table, Subquery:
+		if _, err := b.colName(tab, c); err == nil {
+			res = append(res, tab)
+		}
+	}
+	return res
+}
+
+// subqueryColName 在子查询的结果里面查找列名。
+// 别名直接作为列名；没有别名的列使用它在子查询里面的列名；
+// 子查询是 SELECT * 的时候，在子查询的 FROM 部分里面查找
+func (b *builder) subqueryColName(sub Subquery, c string) (string, error) {
+	if len(sub.columns) == 0 {
+		return b.colName(sub.table, c)
+	}
+	for _, s := range sub.columns {
+		switch col := s.(type) {
+		case Column:
+			if col.alias == c {
+				return c, nil
+			}
+			if col.alias != "" || col.name != c {
+				continue
+			}
+			table := col.table
+			if table == nil {
+				table = sub.table
+			}
+			return b.colName(table, c)
+		case Aggregate:
+			if col.alias == c {
+				return c, nil
+			}
+		case CondAggregate:
+			if col.alias == c {
+				return c, nil
+			}
+		case MathExpr:
+			if col.alias == c {
+				return c, nil
+			}
+		}
+	}
+	return "", errs.NewErrUnknownField(c)
+}
+
+func (b *builder) addArgs(args ...any) {
+	if b.args == nil {
+		b.args = make([]any, 0, 8)
+	}
+	b.args = append(b.args, args...)
+}
+
+// buildArg 写入占位符并且加入参数。
+// 注意 PostgreSQL 的占位符带有序号，所以要先加入参数再计算序号
+func (b *builder) buildArg(arg any) {
+	b.addArgs(arg)
+	b.sb.WriteString(b.db.dialect.buildPlaceholder(b.argOffset + len(b.args)))
+}
+
+// buildRaw 写入原生表达式。每一个 ? 对应一个参数，会按照方言转换成占位符，
+// 这样 PostgreSQL 的 $n 也能够和语句里面其它的参数连续编号。
+// 参数用完之后剩下的 ? 原样写入，没有对应 ? 的参数直接加入参数列表
+func (b *builder) buildRaw(raw RawExpr) {
+	expr := raw.raw
+	i := 0
+	for ; i < len(raw.args); i++ {
+		idx := strings.IndexByte(expr, '?')
+		if idx < 0 {
+			break
+		}
+		b.sb.WriteString(expr[:idx])
+		b.buildArg(raw.args[i])
+		expr = expr[idx+1:]
+	}
+	b.sb.WriteString(expr)
+	if i < len(raw.args) {
+		b.addArgs(raw.args[i:]...)
+	}
+}
+
+// argOffsetBuilder 代表可以从指定序号开始编号占位符的 QueryBuilder
+type argOffsetBuilder interface {
+	buildWithArgOffset(offset int) (*Query, error)
+}
+
+// buildSub 构造嵌入在当前语句中的查询，例如子查询和集合操作，
+// 嵌入的查询的占位符序号接着当前语句已有的参数继续编号
+func (b *builder) buildSub(q QueryBuilder) (*Query, error) {
+	if ob, ok := q.(argOffsetBuilder); ok {
+		return ob.buildWithArgOffset(b.argOffset + len(b.args))
+	}
+	return q.Build()
+}
+
+func (b *builder) buildAs(alias string) error {
+	if alias == "" {
+		return nil
+	}
+	b.sb.WriteString(" AS ")
+	return b.quote(alias)
+}
+
+func (b *builder) buildPredicates(ps []Predicate) error {
+	p := ps[0]
+	for i := 1; i < len(ps); i++ {
+		p = p.And(ps[i])
+	}
+	return b.buildExpression(p)
+}
+
+// validateExpression 校验查询条件里面的列。
+// 子查询在自身构造的时候校验，原生表达式则不做校验
+func (b *builder) validateExpression(e Expression, check func(fd string)) {
+	switch exp := e.(type) {
+	case Predicate:
+		if exp.left != nil {
+			b.validateExpression(exp.left, check)
+		}
+		if exp.right != nil {
+			b.validateExpression(exp.right, check)
+		}
+	case Column:
+		// 指定了表的列在构造的时候再校验
+		if exp.table == nil {
+			check(exp.name)
+		}
+	case Aggregate:
+		check(exp.arg)
+	case MathExpr:
+		b.validateExpression(exp.left, check)
+		b.validateExpression(exp.right, check)
+	}
+}
+
+func (b *builder) buildExpression(e Expression) error {
+	switch e.(type) {
+	case Predicate:
+		return b.buildPredicate(e.(Predicate))
+	case Column:
+		// WHERE 和 HAVING 里面不使用别名
+		c := e.(Column)
+		return b.buildTableColumn(c.table, c.name, "")
+	case value:
+		b.buildArg(e.(value).val)
+	case Aggregate:
+		return b.buildAggregate(e.(Aggregate), false)
+	case valueList:
+		vals := e.(valueList).vals
+		b.sb.WriteByte('(')
+		for i := range vals {
+			if i > 0 {
+				b.sb.WriteByte(',')
+			}
+			b.buildArg(vals[i])
+		}
+		b.sb.WriteByte(')')
+	case MathExpr:
+		m := e.(MathExpr)
+		if err := b.buildOperand(m.left, m.op, false); err != nil {
+			return err
+		}
+		b.sb.WriteString(fmt.Sprintf(" %s ", m.op))
+		return b.buildOperand(m.right, m.op, true)
+	case betweenExpr:
+		be := e.(betweenExpr)
+		if err := b.buildOperand(be.low, opBetween, false); err != nil {
+			return err
+		}
+		b.sb.WriteString(" AND ")
+		return b.buildOperand(be.high, opBetween, true)
+	case Subquery:
+		return b.buildSubquery(e.(Subquery))
+	case RawExpr:
+		b.buildRaw(e.(RawExpr))
+	}
+
+	return nil
+}
+
+// buildAggregate 构造聚合函数，SELECT, HAVING 和 ORDER BY 都使用它，
+// 参数都是字段名，和没有指定表的列一样转换成列名
+func (b *builder) buildAggregate(a Aggregate, useAlias bool) error {
+	b.sb.WriteString(a.fn)
+	b.sb.WriteByte('(')
+	if a.distinct {
+		b.sb.WriteString("DISTINCT ")
+	}
+	if err := b.buildColumn(a.arg, ""); err != nil {
+		return err
+	}
+	b.sb.WriteByte(')')
+	if useAlias {
+		return b.buildAs(a.alias)
+	}
+	return nil
+}
+
+func (b *builder) buildPredicate(p Predicate) error {
+	switch {
+	case p.op == "":
+		// 没有操作符的，例如 RawExpr.AsPredicate，只有左边部分
+		return b.buildExpression(p.left)
+	case p.left == nil:
+		// 前缀操作符，例如 NOT
+		b.sb.WriteString(fmt.Sprintf("%s ", p.op))
+		return b.buildOperand(p.right, p.op, true)
+	case p.right == nil:
+		// 一元操作符放在后面，例如 IS NULL
+		if err := b.buildOperand(p.left, p.op, false); err != nil {
+			return err
+		}
+		b.sb.WriteString(fmt.Sprintf(" %s", p.op))
+		return nil
+	default:
+		if err := b.buildOperand(p.left, p.op, false); err != nil {
+			return err
+		}
+		b.sb.WriteString(fmt.Sprintf(" %s ", p.op))
+		return b.buildOperand(p.right, p.op, true)
+	}
+}
+
+// buildOperand 构造操作符 parent 的操作数，只有在必要的时候才加括号：
+// 操作数的优先级比 parent 低，例如 (a OR b) AND c；
+// 或者是右边的操作数和 parent 优先级相同，但是 parent 不满足结合律，例如 a - (b - c)
+func (b *builder) buildOperand(e Expression, parent op, right bool) error {
+	prec, ok := precedenceOf(e)
+	if !ok {
+		return b.buildExpression(e)
+	}
+	pp := parent.precedence()
+	if prec > pp || (prec == pp && (!right || parent.associative())) {
+		return b.buildExpression(e)
+	}
+	b.sb.WriteByte('(')
+	if err := b.buildExpression(e); err != nil {
+		return err
+	}
+	b.sb.WriteByte(')')
+	return nil
+}
+
+// buildSubquery 构造子查询，子查询的参数按照出现的位置合并到当前的参数里面
+func (b *builder) buildSubquery(sub Subquery) error {
+	q, err := b.buildSub(sub.s)
+	if err != nil {
+		return err
+	}
+	b.sb.WriteByte('(')
+	// 去掉子查询末尾的分号，原生查询不一定以分号结尾
+	b.sb.WriteString(strings.TrimSuffix(q.SQL, ";"))
+	b.sb.WriteByte(')')
+	if len(q.Args) > 0 {
+		b.addArgs(q.Args...)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package orm
 
 type Column struct {
+	// table 是列所属的表，为 nil 的时候使用 Selector 的模型
+	table TableReference
 	name  string
 	alias string
 }
@@ -9,13 +11,24 @@ func (c Column) expr() {}
 
 func (c Column) selectable() {}
 
+// assign 在 UPSERT 中代表使用插入的值更新该列
+func (c Column) assign() {}
+
 func (c Column) As(alias string) Column {
 	return Column {
+		table: c.table,
 		name:  c.name,
 		alias: alias,
 	}
 }
 
+// valueList 代表 IN 后面的值列表
+type valueList struct {
+	vals []any
+}
+
+func (v valueList) expr() {}
+
 type value struct {
 	val any
 }
@@ -32,27 +45,135 @@ func C(name string) Column {
 	return Column{name: name}
 }
 
-// EQ 例如 C("id").Eq(12)
+// EQ 例如 C("id").Eq(12)。
+// arg 可以是另外一个列，例如 C("Id").EQ(C("UserId"))，用于 JOIN 的条件；
+// 也可以是子查询或者聚合函数，其它的都会作为参数传递
 func (c Column) EQ(arg any) Predicate {
+	return c.compare(opEQ, arg)
+}
+
+// NotEQ 例如 C("Id").NotEQ(12)，生成 `id` != ?
+func (c Column) NotEQ(arg any) Predicate {
+	return c.compare(opNEQ, arg)
+}
+
+func (c Column) LT(arg any) Predicate {
+	return c.compare(opLT, arg)
+}
+
+// LTEQ 例如 C("Age").LTEQ(18)，生成 `age` <= ?
+func (c Column) LTEQ(arg any) Predicate {
+	return c.compare(opLTEQ, arg)
+}
+
+func (c Column) GT(arg any) Predicate {
+	return c.compare(opGT, arg)
+}
+
+// GTEQ 例如 C("Age").GTEQ(18)，生成 `age` >= ?
+func (c Column) GTEQ(arg any) Predicate {
+	return c.compare(opGTEQ, arg)
+}
+
+func (c Column) compare(o op, arg any) Predicate {
 	return Predicate{
 		left:  c,
-		op:    opEQ,
+		op:    o,
 		right: exprOf(arg),
 	}
 }
 
-func (c Column) LT(arg any) Predicate {
+// Add 例如 C("Age").Add(1)，一般用于 UPDATE 语句里面自增
+func (c Column) Add(arg any) MathExpr {
+	return mathExprOf(c, opAdd, arg)
+}
+
+// Sub 例如 C("Age").Sub(1)，生成 `age` - ?
+func (c Column) Sub(arg any) MathExpr {
+	return mathExprOf(c, opSub, arg)
+}
+
+// Multi 例如 C("Age").Multi(2)，生成 `age` * ?
+func (c Column) Multi(arg any) MathExpr {
+	return mathExprOf(c, opMulti, arg)
+}
+
+// Like 例如 C("FirstName").Like("%Tom%")，生成 `first_name` LIKE ?。
+// pattern 总是作为参数传递，不会拼接进 SQL 里面
+func (c Column) Like(pattern string) Predicate {
 	return Predicate{
 		left:  c,
-		op:    opLT,
-		right: exprOf(arg),
+		op:    opLike,
+		right: valueOf(pattern),
 	}
 }
 
-func (c Column) GT(arg any) Predicate {
+// NotLike 例如 C("FirstName").NotLike("%Tom%")
+func (c Column) NotLike(pattern string) Predicate {
 	return Predicate{
 		left:  c,
-		op:    opGT,
-		right: exprOf(arg),
+		op:    opNotLike,
+		right: valueOf(pattern),
+	}
+}
+
+// Between 例如 C("Age").Between(18, 30)，生成 `age` BETWEEN ? AND ?
+func (c Column) Between(low, high any) Predicate {
+	return Predicate{
+		left: c,
+		op:   opBetween,
+		right: betweenExpr{
+			low:  exprOf(low),
+			high: exprOf(high),
+		},
+	}
+}
+
+// IsNull 例如 C("LastName").IsNull()，生成 `last_name` IS NULL
+func (c Column) IsNull() Predicate {
+	return Predicate{
+		left: c,
+		op:   opIsNull,
+	}
+}
+
+// IsNotNull 例如 C("LastName").IsNotNull()，生成 `last_name` IS NOT NULL
+func (c Column) IsNotNull() Predicate {
+	return Predicate{
+		left: c,
+		op:   opIsNotNull,
+	}
+}
+
+// In 例如 C("Id").In(1, 2, 3)，生成 `id` IN (?,?,?)。
+// 也可以传入一个子查询，例如 C("Id").In(sub)，
+// sub 可以是 Subquery，也可以是 *Selector 这种 QueryBuilder。
+// 没有传入任何值的时候生成永远为假的条件 1 = 0
+func (c Column) In(vals ...any) Predicate {
+	return c.in(opIn, "1 = 0", vals)
+}
+
+// NotIn 和 In 相反，没有传入任何值的时候生成永远为真的条件 1 = 1
+func (c Column) NotIn(vals ...any) Predicate {
+	return c.in(opNotIn, "1 = 1", vals)
+}
+
+func (c Column) in(o op, empty string, vals []any) Predicate {
+	if len(vals) == 0 {
+		return Raw(empty).AsPredicate()
+	}
+	var right Expression = valueList{vals: vals}
+	if len(vals) == 1 {
+		switch sub := vals[0].(type) {
+		case Subquery:
+			right = sub
+		case QueryBuilder:
+			right = Subquery{s: sub}
+		}
+	}
+	return Predicate{
+		left:  c,
+		op:    o,
+		right: right,
 	}
 }
@@ -1,19 +1,40 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
 	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/valuer"
 	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+	"time"
 )
 
 type DBOption func(*DB)
 
 type DB struct {
+	dialect    Dialect
 	r          model.Registry
 	db         *sql.DB
 	valCreator valuer.Creator
+	// inlineLimitOffset 为 true 的时候，LIMIT 和 OFFSET 的值直接写进 SQL 而不是使用占位符
+	inlineLimitOffset bool
+	// noQuoting 为 true 的时候不再使用引号引用表名，列名和别名
+	noQuoting bool
+	// queryTimeout 大于 0 的时候，作为没有设置超时时间的查询的默认超时时间
+	queryTimeout time.Duration
+	ms           []Middleware
+	// stmts 不为 nil 的时候，查询和语句都使用缓存的预编译语句执行
+	stmts *stmtCache
+	// dryRun 为 true 的时候只构造语句，不会真的执行
+	dryRun bool
+	// strictGroupBy 为 true 的时候，有 GROUP BY 的查询只能选择分组的列和聚合函数
+	strictGroupBy bool
 }
 
+// Open 创建一个 DB 实例。
+// 默认情况下，该 DB 将使用 MySQL 作为方言
+// 如果你使用了其它数据库，可以使用 DBWithDialect 指定。
+// 默认使用反射读写结构体，需要更好的性能可以使用 DBWithUnsafeValuer
 func Open(driver string, dsn string, opts ...DBOption) (*DB, error) {
 	db, err := sql.Open(driver, dsn)
 	if err != nil {
@@ -24,9 +45,10 @@ func Open(driver string, dsn string, opts ...DBOption) (*DB, error) {
 
 func OpenDB(db *sql.DB, opts ...DBOption) (*DB, error) {
 	res := &DB{
+		dialect:    MySQL,
 		r:          model.NewRegistry(),
 		db:         db,
-		valCreator: valuer.NewUnsafeValue,
+		valCreator: valuer.NewReflectValue,
 	}
 	for _, opt := range opts {
 		opt(res)
@@ -34,18 +56,206 @@ func OpenDB(db *sql.DB, opts ...DBOption) (*DB, error) {
 	return res, nil
 }
 
+func DBWithDialect(dialect Dialect) DBOption {
+	return func(db *DB) {
+		db.dialect = dialect
+	}
+}
+
 func DBWithRegistry(r model.Registry) DBOption {
 	return func(db *DB) {
 		db.r = r
 	}
 }
 
+// DBWithInlineLimitOffset 控制 LIMIT 和 OFFSET 是否直接写入数值。
+// 部分驱动或者数据库在不使用占位符的时候能够更好地缓存执行计划。
+// 开启之后，负数的 LIMIT 或者 OFFSET 会返回错误
+func DBWithInlineLimitOffset(inline bool) DBOption {
+	return func(db *DB) {
+		db.inlineLimitOffset = inline
+	}
+}
+
+// DBWithNoQuoting 不再使用方言的引号，直接输出表名，列名和别名。
+// 为了避免 SQL 注入，这种情况下名字只能由字母，数字和下划线组成，并且不能以数字开头
+func DBWithNoQuoting() DBOption {
+	return func(db *DB) {
+		db.noQuoting = true
+	}
+}
+
+// DBWithQueryTimeout 设置查询的默认超时时间。
+// 只有在调用者传入的 ctx 没有设置超时时间的时候才会生效
+func DBWithQueryTimeout(timeout time.Duration) DBOption {
+	return func(db *DB) {
+		db.queryTimeout = timeout
+	}
+}
+
+// DBWithMiddlewares 注册中间件，所有的 Get, GetMulti 和 Exec 都会经过中间件
+func DBWithMiddlewares(ms ...Middleware) DBOption {
+	return func(db *DB) {
+		db.ms = append(db.ms, ms...)
+	}
+}
+
+// DBWithStmtCache 开启预编译语句的缓存，最多缓存 size 个语句，
+// 超过之后淘汰最久没有使用的语句并且关闭它。
+// 缓存以构造好的 SQL 为键，所以只对同样形状的查询有效。事务里面的语句不使用缓存
+func DBWithStmtCache(size int) DBOption {
+	return func(db *DB) {
+		if size > 0 {
+			db.stmts = newStmtCache(size)
+		}
+	}
+}
+
+// DBWithDryRun 开启之后 Get, GetMulti, Iter 和 Exec 都不会调用驱动，
+// 而是返回 *DryRunError，通过 errors.As 可以拿到构造好的查询。
+// 中间件依旧会执行，一般用于测试和调试生成的 SQL
+func DBWithDryRun() DBOption {
+	return func(db *DB) {
+		db.dryRun = true
+	}
+}
+
+// DBWithStrictGroupBy 开启之后，有 GROUP BY 的查询里面，
+// SELECT 的每一个列都必须出现在 GROUP BY 里面，或者放在聚合函数里面，否则 Build 返回错误。
+// MySQL 在某些模式下允许选择没有分组的列，但是 PostgreSQL 不允许，开启之后可以提前发现这种问题
+func DBWithStrictGroupBy() DBOption {
+	return func(db *DB) {
+		db.strictGroupBy = true
+	}
+}
+
+// DBWithReflectValuer 使用反射读写结构体的字段，这是默认的实现
+func DBWithReflectValuer() DBOption {
+	return func(db *DB) {
+		db.valCreator = valuer.NewReflectValue
+	}
+}
+
+// DBWithUnsafeValuer 使用 unsafe 根据字段偏移量直接读写结构体的字段，
+// 性能比反射更好，可以通过 internal/valuer 的 BenchmarkCreator 对比
+func DBWithUnsafeValuer() DBOption {
+	return func(db *DB) {
+		db.valCreator = valuer.NewUnsafeValue
+	}
+}
+
+// DBUseReflectValuer 使用反射读写结构体的字段
+//
+// Deprecated: 使用 DBWithReflectValuer
 func DBUseReflectValuer() DBOption {
 	return func(db *DB) {
 		db.valCreator = valuer.NewReflectValue
 	}
 }
 
+// prepareContext 在执行查询之前检查 ctx，
+// 如果 ctx 已经结束，那么直接返回错误，typ 用于在错误里面标记是哪个构造器；
+// 如果设置了 DBWithQueryTimeout 并且 ctx 没有设置超时时间，那么加上默认的超时时间
+func (db *DB) prepareContext(ctx context.Context, typ string) (context.Context, context.CancelFunc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, errs.NewErrContextDone(typ, err)
+	}
+	if db.queryTimeout <= 0 {
+		return ctx, func() {}, nil
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, db.queryTimeout)
+	return ctx, cancel, nil
+}
+
+// BeginTx 开启事务
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, db: db}, nil
+}
+
+// DoTx 将会开启事务执行 fn。如果 fn 返回错误或者发生 panic，事务将会回滚，
+// 否则提交事务。发生 panic 的时候，回滚之后 panic 会继续向上传播
+func (db *DB) DoTx(ctx context.Context,
+	fn func(ctx context.Context, tx *Tx) error,
+	opts *sql.TxOptions) (err error) {
+	var tx *Tx
+	tx, err = db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			if e := tx.Rollback(); e != nil {
+				err = errs.NewErrFailToRollbackTx(err, e, panicked)
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = fn(ctx, tx)
+	panicked = false
+	return err
+}
+
+func (db *DB) getDB() *DB {
+	return db
+}
+
+func (db *DB) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if db.stmts == nil {
+		return db.db.QueryContext(ctx, query, args...)
+	}
+	stmt, release, err := db.stmts.get(ctx, db.db, query)
+	if err != nil {
+		return nil, err
+	}
+	// 在 Rows 关闭之前，database/sql 会推迟关闭语句，所以这里可以直接释放
+	defer release()
+	return stmt.QueryContext(ctx, args...)
+}
+
+func (db *DB) queryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if db.stmts == nil {
+		return db.db.QueryRowContext(ctx, query, args...)
+	}
+	stmt, release, err := db.stmts.get(ctx, db.db, query)
+	if err != nil {
+		// sql.Row 没有办法直接携带错误，所以退化成不预编译，让它来返回错误
+		return db.db.QueryRowContext(ctx, query, args...)
+	}
+	defer release()
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+func (db *DB) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if db.stmts == nil {
+		return db.db.ExecContext(ctx, query, args...)
+	}
+	stmt, release, err := db.stmts.get(ctx, db.db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.ExecContext(ctx, args...)
+}
+
+// Close 关闭缓存的预编译语句和底层的 sql.DB
+func (db *DB) Close() error {
+	if db.stmts != nil {
+		db.stmts.close()
+	}
+	return db.db.Close()
+}
+
 // MustNewDB 创建一个 DB，如果失败则会 panic
 // 我个人不太喜欢这种
 func MustNewDB(driver string, dsn string, opts ...DBOption) *DB {
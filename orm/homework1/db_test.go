@@ -0,0 +1,347 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/valuer"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDB_CanceledContext(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithQueryTimeout(time.Second))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name    string
+		exec    func() error
+		wantErr error
+	}{
+		{
+			name: "selector get",
+			exec: func() error {
+				_, err := NewSelector[TestModel](db).Get(ctx)
+				return err
+			},
+			wantErr: errs.NewErrContextDone("Selector", context.Canceled),
+		},
+		{
+			name: "selector get multi",
+			exec: func() error {
+				_, err := NewSelector[TestModel](db).GetMulti(ctx)
+				return err
+			},
+			wantErr: errs.NewErrContextDone("Selector", context.Canceled),
+		},
+		{
+			name: "selector iter",
+			exec: func() error {
+				_, err := NewSelector[TestModel](db).Iter(ctx)
+				return err
+			},
+			wantErr: errs.NewErrContextDone("Selector", context.Canceled),
+		},
+		{
+			name: "inserter",
+			exec: func() error {
+				_, err := NewInserter[TestModel](db).Values(&TestModel{}).Exec(ctx)
+				return err
+			},
+			wantErr: errs.NewErrContextDone("Inserter", context.Canceled),
+		},
+		{
+			name: "updater",
+			exec: func() error {
+				_, err := NewUpdater[TestModel](db).Set(Assign("Age", 18)).Exec(ctx)
+				return err
+			},
+			wantErr: errs.NewErrContextDone("Updater", context.Canceled),
+		},
+		{
+			name: "deleter",
+			exec: func() error {
+				_, err := NewDeleter[TestModel](db).Exec(ctx)
+				return err
+			},
+			wantErr: errs.NewErrContextDone("Deleter", context.Canceled),
+		},
+		{
+			name: "raw query",
+			exec: func() error {
+				_, err := RawQuery[TestModel](db, "SELECT * FROM `test_model`").Get(ctx)
+				return err
+			},
+			wantErr: errs.NewErrContextDone("RawQuerier", context.Canceled),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.exec()
+			assert.Equal(t, tc.wantErr, err)
+			assert.True(t, errors.Is(err, context.Canceled))
+		})
+	}
+	// 没有任何查询被发送到数据库
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDB_QueryTimeout(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithQueryTimeout(time.Millisecond*10))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	start := time.Now()
+	_, err = NewSelector[TestModel](db).Get(context.Background())
+	assert.True(t, errors.Is(err, sqlmock.ErrCancelled))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDB_prepareContext(t *testing.T) {
+	testCases := []struct {
+		name    string
+		timeout time.Duration
+		ctx     func() (context.Context, context.CancelFunc)
+
+		wantDeadline bool
+		// wantTimeout 是期望的超时时间，和 ctx 创建的时间相比
+		wantTimeout time.Duration
+	}{
+		{
+			name: "no timeout",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.Background(), func() {}
+			},
+		},
+		{
+			name:    "default timeout",
+			timeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.Background(), func() {}
+			},
+			wantDeadline: true,
+			wantTimeout:  time.Minute,
+		},
+		{
+			// 调用者设置的超时时间优先
+			name:    "caller deadline",
+			timeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Hour)
+			},
+			wantDeadline: true,
+			wantTimeout:  time.Hour,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &DB{queryTimeout: tc.timeout}
+			start := time.Now()
+			parent, cancelParent := tc.ctx()
+			defer cancelParent()
+			ctx, cancel, err := db.prepareContext(parent, "Selector")
+			require.NoError(t, err)
+			defer cancel()
+			end := time.Now()
+			deadline, ok := ctx.Deadline()
+			assert.Equal(t, tc.wantDeadline, ok)
+			if !ok {
+				return
+			}
+			assert.False(t, deadline.Before(start.Add(tc.wantTimeout)))
+			assert.False(t, deadline.After(end.Add(tc.wantTimeout)))
+		})
+	}
+}
+
+func TestDB_DryRun(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	var sqls []string
+	db, err := OpenDB(mockDB, DBWithDryRun(), DBWithMiddlewares(func(next Handler) Handler {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			sqls = append(sqls, qc.Query.SQL)
+			return next(ctx, qc)
+		}
+	}))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name      string
+		exec      func() error
+		wantQuery *Query
+	}{
+		{
+			name: "selector get",
+			exec: func() error {
+				_, err := NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(ctx)
+				return err
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` = ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "selector get multi",
+			exec: func() error {
+				_, err := NewSelector[TestModel](db).GetMulti(ctx)
+				return err
+			},
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model`;",
+			},
+		},
+		{
+			name: "selector iter",
+			exec: func() error {
+				_, err := NewSelector[TestModel](db).Iter(ctx)
+				return err
+			},
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model`;",
+			},
+		},
+		{
+			name: "inserter",
+			exec: func() error {
+				_, err := NewInserter[TestModel](db).Values(&TestModel{Age: 18}).
+					Columns("Age").Exec(ctx)
+				return err
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`age`) VALUES (?);",
+				Args: []any{int8(18)},
+			},
+		},
+		{
+			name: "updater",
+			exec: func() error {
+				_, err := NewUpdater[TestModel](db).Set(Assign("Age", 18)).Exec(ctx)
+				return err
+			},
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "deleter",
+			exec: func() error {
+				_, err := NewDeleter[TestModel](db).Exec(ctx)
+				return err
+			},
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_model`;",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.exec()
+			assert.True(t, errors.Is(err, ErrDryRun))
+			var dryRunErr *DryRunError
+			require.True(t, errors.As(err, &dryRunErr))
+			assert.Equal(t, tc.wantQuery, dryRunErr.Query)
+		})
+	}
+	// 中间件依旧执行，但是没有调用驱动
+	assert.Len(t, sqls, len(testCases))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestToSQL(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name  string
+		q     QueryBuilder
+		toSQL func() (string, []any, error)
+	}{
+		{
+			name:  "selector",
+			q:     NewSelector[TestModel](db).Where(C("Id").EQ(1)),
+			toSQL: NewSelector[TestModel](db).Where(C("Id").EQ(1)).ToSQL,
+		},
+		{
+			name:  "inserter",
+			q:     NewInserter[TestModel](db).Values(&TestModel{Id: 1}),
+			toSQL: NewInserter[TestModel](db).Values(&TestModel{Id: 1}).ToSQL,
+		},
+		{
+			name:  "updater",
+			q:     NewUpdater[TestModel](db).Set(Assign("Age", 18)).Where(C("Id").EQ(1)),
+			toSQL: NewUpdater[TestModel](db).Set(Assign("Age", 18)).Where(C("Id").EQ(1)).ToSQL,
+		},
+		{
+			name:  "deleter",
+			q:     NewDeleter[TestModel](db).Where(C("Id").EQ(1)),
+			toSQL: NewDeleter[TestModel](db).Where(C("Id").EQ(1)).ToSQL,
+		},
+		{
+			name:  "build error",
+			q:     NewSelector[TestModel](db).Where(C("Invalid").EQ(1)),
+			toSQL: NewSelector[TestModel](db).Where(C("Invalid").EQ(1)).ToSQL,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, wantErr := tc.q.Build()
+			sql, args, err := tc.toSQL()
+			assert.Equal(t, wantErr, err)
+			if err != nil {
+				assert.Empty(t, sql)
+				assert.Nil(t, args)
+				return
+			}
+			assert.Equal(t, query.SQL, sql)
+			assert.Equal(t, query.Args, args)
+		})
+	}
+}
+
+func TestDB_Valuer(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []DBOption
+		want valuer.Creator
+	}{
+		{
+			// 默认使用反射
+			name: "default",
+			want: valuer.NewReflectValue,
+		},
+		{
+			name: "reflect",
+			opts: []DBOption{DBWithUnsafeValuer(), DBWithReflectValuer()},
+			want: valuer.NewReflectValue,
+		},
+		{
+			name: "unsafe",
+			opts: []DBOption{DBWithUnsafeValuer()},
+			want: valuer.NewUnsafeValue,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", tc.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, reflect.ValueOf(tc.want).Pointer(), reflect.ValueOf(db.valCreator).Pointer())
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package orm
+
+import (
+	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"strings"
+	"time"
+)
+
+var _ Executor = &Deleter[any]{}
+
+// Deleter 用于构造 DELETE 语句
+type Deleter[T any] struct {
+	builder
+	table string
+	// alias 是表的别名
+	alias string
+	where []Predicate
+	// unscoped 为 true 的时候，即便开启了软删除也直接删除数据
+	unscoped bool
+}
+
+func NewDeleter[T any](sess Session) *Deleter[T] {
+	return &Deleter[T]{
+		builder: newBuilder(sess),
+	}
+}
+
+// From 指定表名，如果是空字符串，那么将会使用默认表名。
+// 表名会被加上引号，例如 From("user") 生成 `user`，
+// 也可以带上数据库名，例如 From("db.user") 生成 `db`.`user`
+func (d *Deleter[T]) From(tbl string) *Deleter[T] {
+	d.table = tbl
+	return d
+}
+
+// As 指定表的别名，例如 From("user").As("u") 生成 `user` AS `u`。
+// 使用别名之后，可以通过 TableOf[User]().As("u").C("Id") 引用列，生成 `u`.`id`
+func (d *Deleter[T]) As(alias string) *Deleter[T] {
+	d.alias = alias
+	return d
+}
+
+// Where 用于构造 WHERE 查询条件。如果 ps 长度为 0，那么不会构造 WHERE 部分，
+// 也就是会删除全部数据
+func (d *Deleter[T]) Where(ps ...Predicate) *Deleter[T] {
+	d.where = ps
+	return d
+}
+
+// Unscoped 直接删除数据，而不是软删除
+func (d *Deleter[T]) Unscoped() *Deleter[T] {
+	d.unscoped = true
+	return d
+}
+
+// Build 构造 DELETE 语句。
+// 如果模型开启了软删除，那么构造的是设置软删除字段的 UPDATE 语句，
+// 并且只会修改还没有被删除的数据
+func (d *Deleter[T]) Build() (*Query, error) {
+	var (
+		t   T
+		err error
+	)
+	if err = d.resolveModel(&t); err != nil {
+		return nil, err
+	}
+	if d.model.ReadOnly {
+		return nil, errs.NewErrReadOnlyModel(d.model.TableName)
+	}
+	d.sb.Reset()
+	d.args = nil
+
+	where := d.where
+	if fd := d.model.SoftDelete; fd != nil && !d.unscoped {
+		d.sb.WriteString("UPDATE ")
+		if err = d.buildTable(); err != nil {
+			return nil, err
+		}
+		d.sb.WriteString(" SET ")
+		if err = d.buildColumn(fd.GoName, ""); err != nil {
+			return nil, err
+		}
+		d.sb.WriteByte('=')
+		d.buildArg(time.Now())
+		where = make([]Predicate, 0, len(d.where)+1)
+		where = append(append(where, d.where...), C(fd.GoName).IsNull())
+	} else {
+		d.sb.WriteString("DELETE FROM ")
+		if err = d.buildTable(); err != nil {
+			return nil, err
+		}
+	}
+	if len(where) > 0 {
+		d.sb.WriteString(" WHERE ")
+		if err = d.buildPredicates(where); err != nil {
+			return nil, err
+		}
+	}
+	d.sb.WriteByte(';')
+	return &Query{
+		SQL:  d.sb.String(),
+		Args: d.args,
+	}, nil
+}
+
+func (d *Deleter[T]) buildTable() error {
+	if d.table == "" {
+		if err := d.quote(d.model.TableName); err != nil {
+			return err
+		}
+		return d.buildAs(d.alias)
+	}
+	for i, seg := range strings.Split(d.table, ".") {
+		if i > 0 {
+			d.sb.WriteByte('.')
+		}
+		if err := d.quote(seg); err != nil {
+			return err
+		}
+	}
+	return d.buildAs(d.alias)
+}
+
+// ToSQL 返回 Build 构造的 SQL 和参数，不会执行
+func (d *Deleter[T]) ToSQL() (string, []any, error) {
+	return toSQL(d.Build())
+}
+
+func (d *Deleter[T]) Exec(ctx context.Context) (Result, error) {
+	q, err := d.Build()
+	if err != nil {
+		return Result{err: err}, err
+	}
+	ctx, cancel, err := d.db.prepareContext(ctx, "Deleter")
+	if err != nil {
+		return Result{err: err}, err
+	}
+	defer cancel()
+	return d.exec(ctx, "DELETE", q)
+}
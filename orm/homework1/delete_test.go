@@ -0,0 +1,202 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestDeleter_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "no where",
+			q:    NewDeleter[TestModel](db),
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_model`;",
+			},
+		},
+		{
+			name: "from",
+			q:    NewDeleter[TestModel](db).From("test_model_t"),
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_model_t`;",
+			},
+		},
+		{
+			name: "from with db",
+			q:    NewDeleter[TestModel](db).From("test_db.test_model_t"),
+			wantQuery: &Query{
+				SQL: "DELETE FROM `test_db`.`test_model_t`;",
+			},
+		},
+		{
+			name: "from with alias",
+			q: NewDeleter[TestModel](db).From("test_model_t").As("t").
+				Where(TableOf[TestModel]().As("t").C("Id").EQ(16)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model_t` AS `t` WHERE `t`.`id` = ?;",
+				Args: []any{16},
+			},
+		},
+		{
+			name: "alias",
+			q: NewDeleter[TestModel](db).As("t").
+				Where(TableOf[TestModel]().As("t").C("Age").GT(18), C("Id").EQ(16)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` AS `t` WHERE `t`.`age` > ? AND `id` = ?;",
+				Args: []any{18, 16},
+			},
+		},
+		{
+			name: "where",
+			q:    NewDeleter[TestModel](db).Where(C("Id").EQ(16)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `id` = ?;",
+				Args: []any{16},
+			},
+		},
+		{
+			name: "and or",
+			q: NewDeleter[TestModel](db).
+				Where(C("Age").GT(18).And(C("Age").LT(60)).Or(C("FirstName").EQ("Tom"))),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `age` > ? AND `age` < ? OR `first_name` = ?;",
+				Args: []any{18, 60, "Tom"},
+			},
+		},
+		{
+			// 多个条件用 AND 连接
+			name: "multiple predicates",
+			q:    NewDeleter[TestModel](db).Where(C("Age").GT(18), Not(C("Id").EQ(1))),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `test_model` WHERE `age` > ? AND NOT `id` = ?;",
+				Args: []any{18, 1},
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewDeleter[TestModel](db).Where(C("Invalid").EQ(1)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestDeleter_ReadOnlyModel(t *testing.T) {
+	type DeleteView struct {
+		Id int64
+	}
+	r := model.NewRegistry()
+	if _, err := r.Register(&DeleteView{}, model.WithReadOnly()); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithRegistry(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewDeleter[DeleteView](db).Where(C("Id").EQ(1)).Build()
+	assert.Equal(t, errs.NewErrReadOnlyModel("delete_view"), err)
+}
+
+func TestDeleter_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `test_model` WHERE `id` = ?;")).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE .*").WillReturnError(errors.New("exec error"))
+
+	res, err := NewDeleter[TestModel](db).Where(C("Id").EQ(1)).Exec(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	_, err = NewDeleter[TestModel](db).Exec(context.Background())
+	assert.Equal(t, errors.New("exec error"), err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleter_SoftDelete(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name    string
+		q       QueryBuilder
+		wantSQL string
+		// wantArgs 不包括删除时间
+		wantArgs []any
+	}{
+		{
+			name:     "no where",
+			q:        NewDeleter[SoftDeleteModel](db),
+			wantSQL:  "UPDATE `soft_delete_model` SET `deleted_at`=? WHERE `deleted_at` IS NULL;",
+			wantArgs: []any{},
+		},
+		{
+			name: "where",
+			q: NewDeleter[SoftDeleteModel](db).
+				Where(C("Id").EQ(1).Or(C("Name").EQ("Tom"))),
+			wantSQL:  "UPDATE `soft_delete_model` SET `deleted_at`=? WHERE (`id` = ? OR `name` = ?) AND `deleted_at` IS NULL;",
+			wantArgs: []any{1, "Tom"},
+		},
+		{
+			name:     "from",
+			q:        NewDeleter[SoftDeleteModel](db).From("soft_delete_model_t"),
+			wantSQL:  "UPDATE `soft_delete_model_t` SET `deleted_at`=? WHERE `deleted_at` IS NULL;",
+			wantArgs: []any{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			query, err := tc.q.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantSQL, query.SQL)
+			require.Len(t, query.Args, len(tc.wantArgs)+1)
+			deletedAt, ok := query.Args[0].(time.Time)
+			require.True(t, ok)
+			assert.False(t, deletedAt.Before(start))
+			assert.Equal(t, tc.wantArgs, query.Args[1:])
+		})
+	}
+
+	// Unscoped 直接删除
+	query, err := NewDeleter[SoftDeleteModel](db).Where(C("Id").EQ(1)).Unscoped().Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "DELETE FROM `soft_delete_model` WHERE `id` = ?;",
+		Args: []any{1},
+	}, query)
+}
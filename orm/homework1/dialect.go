@@ -0,0 +1,202 @@
+package orm
+
+import (
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"strconv"
+)
+
+var (
+	MySQL    Dialect = &mysqlDialect{}
+	SQLite3  Dialect = &sqlite3Dialect{}
+	Postgres Dialect = &postgresDialect{}
+)
+
+// Dialect 代表不同数据库之间的差异
+type Dialect interface {
+	// quoter 返回引用表名，列名和别名的引号
+	quoter() byte
+	// buildPlaceholder 返回第 i 个参数的占位符，i 从 1 开始
+	buildPlaceholder(i int) string
+	// randomFunc 返回随机排序所用的函数
+	randomFunc() string
+	// supportSetOp 是否支持集合操作，例如 INTERSECT
+	supportSetOp(op string) bool
+	// supportAggregateFilter 是否支持 COUNT(*) FILTER (WHERE ...)
+	supportAggregateFilter() bool
+	// noLimit 返回代表不限制行数的 LIMIT 值，
+	// 用于只设置了 OFFSET 的情况。返回空字符串说明可以单独使用 OFFSET
+	noLimit() string
+	// buildUpsert 构造插入冲突部分
+	buildUpsert(b *builder, odk *Upsert) error
+	// lockClause 返回锁定读对应的子句，返回空字符串说明不支持
+	lockClause(lock string) string
+}
+
+// standardSQL 是标准 SQL 的实现，其它方言在它的基础上覆盖差异部分
+type standardSQL struct {
+}
+
+func (s *standardSQL) quoter() byte {
+	return '"'
+}
+
+func (s *standardSQL) buildPlaceholder(i int) string {
+	return "?"
+}
+
+func (s *standardSQL) randomFunc() string {
+	return "RANDOM()"
+}
+
+func (s *standardSQL) supportSetOp(op string) bool {
+	return true
+}
+
+func (s *standardSQL) supportAggregateFilter() bool {
+	return false
+}
+
+func (s *standardSQL) noLimit() string {
+	return ""
+}
+
+func (s *standardSQL) lockClause(lock string) string {
+	return lock
+}
+
+// buildUpsert 标准 SQL 使用 ON CONFLICT，SQLite3 和 PostgreSQL 都支持。
+// PostgreSQL 的 DO UPDATE 必须指定冲突的列，所以没有指定的时候返回错误
+func (s *standardSQL) buildUpsert(b *builder, odk *Upsert) error {
+	if len(odk.conflictColumns) == 0 {
+		return errs.ErrUpsertNoConflictColumns
+	}
+	b.sb.WriteString(" ON CONFLICT(")
+	for i, col := range odk.conflictColumns {
+		if i > 0 {
+			b.sb.WriteByte(',')
+		}
+		if err := b.buildColumn(col, ""); err != nil {
+			return err
+		}
+	}
+	b.sb.WriteString(") DO UPDATE SET ")
+	for idx, a := range odk.assigns {
+		if idx > 0 {
+			b.sb.WriteByte(',')
+		}
+		switch assign := a.(type) {
+		case Column:
+			if err := b.buildColumn(assign.name, ""); err != nil {
+				return err
+			}
+			b.sb.WriteString("=excluded.")
+			if err := b.buildColumn(assign.name, ""); err != nil {
+				return err
+			}
+		case Assignment:
+			if err := b.buildColumn(assign.column, ""); err != nil {
+				return err
+			}
+			b.sb.WriteByte('=')
+			if err := b.buildExpression(assign.val); err != nil {
+				return err
+			}
+		default:
+			return errs.NewErrUnsupportedAssignable(a)
+		}
+	}
+	return nil
+}
+
+type mysqlDialect struct {
+	standardSQL
+}
+
+func (m *mysqlDialect) quoter() byte {
+	return '`'
+}
+
+func (m *mysqlDialect) randomFunc() string {
+	return "RAND()"
+}
+
+// noLimit MySQL 没有办法单独使用 OFFSET，官方建议的是使用一个足够大的数字
+func (m *mysqlDialect) noLimit() string {
+	return "18446744073709551615"
+}
+
+func (m *mysqlDialect) buildUpsert(b *builder, odk *Upsert) error {
+	b.sb.WriteString(" ON DUPLICATE KEY UPDATE ")
+	for idx, a := range odk.assigns {
+		if idx > 0 {
+			b.sb.WriteByte(',')
+		}
+		switch assign := a.(type) {
+		case Column:
+			if err := b.buildColumn(assign.name, ""); err != nil {
+				return err
+			}
+			b.sb.WriteString("=VALUES(")
+			if err := b.buildColumn(assign.name, ""); err != nil {
+				return err
+			}
+			b.sb.WriteByte(')')
+		case Assignment:
+			if err := b.buildColumn(assign.column, ""); err != nil {
+				return err
+			}
+			b.sb.WriteByte('=')
+			if err := b.buildExpression(assign.val); err != nil {
+				return err
+			}
+		default:
+			return errs.NewErrUnsupportedAssignable(a)
+		}
+	}
+	return nil
+}
+
+// lockClause MySQL 8.0 之前不支持 FOR SHARE，所以使用 LOCK IN SHARE MODE
+func (m *mysqlDialect) lockClause(lock string) string {
+	if lock == lockForShare {
+		return "LOCK IN SHARE MODE"
+	}
+	return lock
+}
+
+// supportSetOp MySQL 只支持 UNION
+func (m *mysqlDialect) supportSetOp(op string) bool {
+	return op == setOpUnion || op == setOpUnionAll
+}
+
+type sqlite3Dialect struct {
+	standardSQL
+}
+
+// quoter SQLite3 同样支持反引号
+func (s *sqlite3Dialect) quoter() byte {
+	return '`'
+}
+
+// lockClause SQLite3 锁的是整个数据库，不支持行锁
+func (s *sqlite3Dialect) lockClause(lock string) string {
+	return ""
+}
+
+// noLimit SQLite3 里面负数的 LIMIT 代表不限制
+func (s *sqlite3Dialect) noLimit() string {
+	return "-1"
+}
+
+type postgresDialect struct {
+	standardSQL
+}
+
+// buildPlaceholder PostgreSQL 使用 $1, $2 这种带序号的占位符
+func (p *postgresDialect) buildPlaceholder(i int) string {
+	return "$" + strconv.Itoa(i)
+}
+
+func (p *postgresDialect) supportAggregateFilter() bool {
+	return true
+}
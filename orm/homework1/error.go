@@ -1,9 +1,28 @@
 package orm
 
-import "gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+import (
+	"fmt"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+)
 
 // 将内部的 sentinel error 暴露出去
 var (
 	// ErrNoRows 代表没有找到数据
 	ErrNoRows = errs.ErrNoRows
+	// ErrDryRun 代表开启了 DBWithDryRun，语句没有被执行
+	ErrDryRun = errs.ErrDryRun
 )
+
+// DryRunError 是开启 DBWithDryRun 之后返回的错误，Query 是本应执行的查询。
+// errors.Is(err, ErrDryRun) 可以判断是不是这种错误
+type DryRunError struct {
+	Query *Query
+}
+
+func (e *DryRunError) Error() string {
+	return fmt.Sprintf("%s, SQL: %s", ErrDryRun.Error(), e.Query.SQL)
+}
+
+func (e *DryRunError) Unwrap() error {
+	return ErrDryRun
+}
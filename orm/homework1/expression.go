@@ -1,7 +1,8 @@
 package orm
 
 // RawExpr 代表一个原生表达式
-// 意味着 ORM 不会对它进行任何处理
+// 意味着 ORM 不会对它进行任何处理，
+// 除了占位符：? 会按照方言转换，例如 PostgreSQL 会转换成 $1 这种带序号的占位符
 type RawExpr struct {
 	raw  string
 	args []interface{}
@@ -11,6 +12,11 @@ func (r RawExpr) selectable() {}
 
 func (r RawExpr) expr() {}
 
+// tableAlias 用于 From(Raw("...")) 直接指定表
+func (r RawExpr) tableAlias() string {
+	return ""
+}
+
 func (r RawExpr) AsPredicate() Predicate {
 	return Predicate{
 		left: r,
@@ -23,4 +29,58 @@ func Raw(expr string, args ...interface{}) RawExpr {
 		raw:  expr,
 		args: args,
 	}
-}
\ No newline at end of file
+}
+// MathExpr 代表算术表达式，例如 `age` + ?。
+// 可以用在 UPDATE 的赋值语句里面，也可以作为 SELECT 的列
+type MathExpr struct {
+	left  Expression
+	op    op
+	right Expression
+	// alias 只在 SELECT 的列里面使用
+	alias string
+}
+
+func (m MathExpr) expr() {}
+
+func (m MathExpr) selectable() {}
+
+func mathExprOf(left Expression, o op, arg any) MathExpr {
+	return MathExpr{
+		left:  left,
+		op:    o,
+		right: exprOf(arg),
+	}
+}
+
+// As 例如 C("Age").Multi(2).As("double_age")
+func (m MathExpr) As(alias string) MathExpr {
+	return MathExpr{
+		left:  m.left,
+		op:    m.op,
+		right: m.right,
+		alias: alias,
+	}
+}
+
+// Add 例如 C("Age").Multi(2).Add(1)，生成 `age` * ? + ?。
+// 在构造 SQL 的时候会根据优先级决定要不要加括号，
+// 例如 C("Age").Add(1).Multi(2) 生成 (`age` + ?) * ?
+func (m MathExpr) Add(arg any) MathExpr {
+	return mathExprOf(m, opAdd, arg)
+}
+
+func (m MathExpr) Sub(arg any) MathExpr {
+	return mathExprOf(m, opSub, arg)
+}
+
+func (m MathExpr) Multi(arg any) MathExpr {
+	return mathExprOf(m, opMulti, arg)
+}
+
+// betweenExpr 代表 BETWEEN 后面的区间，例如 ? AND ?
+type betweenExpr struct {
+	low  Expression
+	high Expression
+}
+
+func (b betweenExpr) expr() {}
@@ -0,0 +1,175 @@
+package orm
+
+import (
+	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+)
+
+var _ Executor = &Inserter[any]{}
+
+// UpsertBuilder 用于构造 UPSERT 语句
+type UpsertBuilder[T any] struct {
+	i               *Inserter[T]
+	conflictColumns []string
+}
+
+// Upsert 代表插入冲突的时候的更新部分
+type Upsert struct {
+	conflictColumns []string
+	assigns         []Assignable
+}
+
+// ConflictColumns 指定冲突的列，传入的是字段名。
+// SQLite3 和 PostgreSQL 必须指定；MySQL 不需要指定，会被忽略
+func (o *UpsertBuilder[T]) ConflictColumns(cols ...string) *UpsertBuilder[T] {
+	o.conflictColumns = cols
+	return o
+}
+
+// Update 指定冲突的时候更新的列，可以混合使用：
+// Assign("Age", 18) 代表更新为 18，C("Age") 代表更新为插入的值
+// Update 可以看做是一个终结方法，重新回到 Inserter 里面
+func (o *UpsertBuilder[T]) Update(assigns ...Assignable) *Inserter[T] {
+	o.i.upsert = &Upsert{
+		conflictColumns: o.conflictColumns,
+		assigns:         assigns,
+	}
+	return o.i
+}
+
+// Inserter 用于构造 INSERT 语句
+type Inserter[T any] struct {
+	builder
+	values  []*T
+	columns []string
+	upsert  *Upsert
+}
+
+func NewInserter[T any](sess Session) *Inserter[T] {
+	return &Inserter[T]{
+		builder: newBuilder(sess),
+	}
+}
+
+// Values 指定要插入的数据，可以一次插入多行
+func (i *Inserter[T]) Values(vals ...*T) *Inserter[T] {
+	i.values = vals
+	return i
+}
+
+// Columns 指定要插入的列，传入的是字段名。
+// 如果没有指定，那么会按照字段定义的顺序插入全部列
+func (i *Inserter[T]) Columns(cols ...string) *Inserter[T] {
+	i.columns = cols
+	return i
+}
+
+// OnDuplicateKey 构造 UPSERT 语句，
+// 在 MySQL 里面是 ON DUPLICATE KEY UPDATE，在其它数据库里面是 ON CONFLICT
+func (i *Inserter[T]) OnDuplicateKey() *UpsertBuilder[T] {
+	return &UpsertBuilder[T]{
+		i: i,
+	}
+}
+
+func (i *Inserter[T]) Build() (*Query, error) {
+	if len(i.values) == 0 {
+		return nil, errs.ErrInsertZeroRow
+	}
+	var err error
+	if err = i.resolveModel(i.values[0]); err != nil {
+		return nil, err
+	}
+	if i.model.ReadOnly {
+		return nil, errs.NewErrReadOnlyModel(i.model.TableName)
+	}
+	i.sb.Reset()
+	i.args = nil
+
+	i.sb.WriteString("INSERT INTO ")
+	if err = i.quote(i.model.TableName); err != nil {
+		return nil, err
+	}
+	i.sb.WriteByte('(')
+
+	fields := i.model.Fields
+	if len(i.columns) > 0 {
+		fields = make([]*model.Field, 0, len(i.columns))
+		for _, c := range i.columns {
+			fd, ok := i.model.FieldMap[c]
+			if !ok {
+				return nil, errs.NewErrUnknownField(c)
+			}
+			fields = append(fields, fd)
+		}
+	}
+	for idx, fd := range fields {
+		if idx > 0 {
+			i.sb.WriteByte(',')
+		}
+		if err = i.quote(fd.ColName); err != nil {
+			return nil, err
+		}
+	}
+
+	i.sb.WriteString(") VALUES ")
+	// (len(i.values) + 1) 中 +1 是考虑到 UPSERT 语句会传递额外的参数
+	i.args = make([]any, 0, len(fields)*(len(i.values)+1))
+	for vIdx, val := range i.values {
+		if vIdx > 0 {
+			i.sb.WriteByte(',')
+		}
+		refVal := i.db.valCreator(val, i.model)
+		i.sb.WriteByte('(')
+		for fIdx, fd := range fields {
+			if fIdx > 0 {
+				i.sb.WriteByte(',')
+			}
+			fdVal, err := refVal.Field(fd.GoName)
+			if err != nil {
+				return nil, err
+			}
+			if fd.Converter != nil {
+				fdVal, err = fd.Converter.ToDB(fdVal)
+				if err != nil {
+					return nil, err
+				}
+			}
+			i.buildArg(fdVal)
+		}
+		i.sb.WriteByte(')')
+	}
+
+	if i.upsert != nil {
+		if len(i.upsert.assigns) == 0 {
+			return nil, errs.ErrUpsertNoAssignment
+		}
+		if err = i.db.dialect.buildUpsert(&i.builder, i.upsert); err != nil {
+			return nil, err
+		}
+	}
+	i.sb.WriteByte(';')
+	return &Query{
+		SQL:  i.sb.String(),
+		Args: i.args,
+	}, nil
+}
+
+// ToSQL 返回 Build 构造的 SQL 和参数，不会执行
+func (i *Inserter[T]) ToSQL() (string, []any, error) {
+	return toSQL(i.Build())
+}
+
+func (i *Inserter[T]) Exec(ctx context.Context) (Result, error) {
+	q, err := i.Build()
+	if err != nil {
+		return Result{err: err}, err
+	}
+	ctx, cancel, err := i.db.prepareContext(ctx, "Inserter")
+	if err != nil {
+		return Result{err: err}, err
+	}
+	defer cancel()
+	return i.exec(ctx, "INSERT", q)
+}
@@ -0,0 +1,312 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestInserter_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "no value",
+			q:       NewInserter[TestModel](db),
+			wantErr: errs.ErrInsertZeroRow,
+		},
+		{
+			name: "single value",
+			q: NewInserter[TestModel](db).Values(&TestModel{
+				Id:        1,
+				FirstName: "Deng",
+				Age:       18,
+				LastName:  &sql.NullString{String: "Ming", Valid: true},
+			}),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?);",
+				Args: []any{int64(1), "Deng", int8(18),
+					&sql.NullString{String: "Ming", Valid: true}},
+			},
+		},
+		{
+			name: "multiple values",
+			q: NewInserter[TestModel](db).Values(
+				&TestModel{
+					Id:        1,
+					FirstName: "Deng",
+					Age:       18,
+					LastName:  &sql.NullString{String: "Ming", Valid: true},
+				},
+				&TestModel{
+					Id:        2,
+					FirstName: "Da",
+					Age:       19,
+				}),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`,`last_name`) VALUES (?,?,?,?),(?,?,?,?);",
+				Args: []any{int64(1), "Deng", int8(18), &sql.NullString{String: "Ming", Valid: true},
+					int64(2), "Da", int8(19), (*sql.NullString)(nil)},
+			},
+		},
+		{
+			// 指定列
+			name: "specify columns",
+			q: NewInserter[TestModel](db).Values(
+				&TestModel{
+					Id:        1,
+					FirstName: "Deng",
+					Age:       18,
+				},
+				&TestModel{
+					Id:        2,
+					FirstName: "Da",
+					Age:       19,
+				}).Columns("FirstName", "Age"),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`first_name`,`age`) VALUES (?,?),(?,?);",
+				Args: []any{"Deng", int8(18), "Da", int8(19)},
+			},
+		},
+		{
+			name: "invalid column",
+			q: NewInserter[TestModel](db).Values(&TestModel{Id: 1}).
+				Columns("FirstName", "Invalid"),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestInserter_BuildWithConverter(t *testing.T) {
+	type InsertUnixTime struct {
+		Id        int64
+		CreatedAt time.Time `orm:"type=unixtime"`
+	}
+	db := memoryDB(t)
+	query, err := NewInserter[InsertUnixTime](db).Values(
+		&InsertUnixTime{Id: 1, CreatedAt: time.Unix(1665331200, 0)},
+		&InsertUnixTime{Id: 2}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 零值会被转换为 NULL
+	assert.Equal(t, &Query{
+		SQL:  "INSERT INTO `insert_unix_time`(`id`,`created_at`) VALUES (?,?),(?,?);",
+		Args: []any{int64(1), int64(1665331200), int64(2), nil},
+	}, query)
+}
+
+func TestInserter_ReadOnlyModel(t *testing.T) {
+	type InsertView struct {
+		Id int64
+	}
+	r := model.NewRegistry()
+	if _, err := r.Register(&InsertView{}, model.WithReadOnly()); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithRegistry(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewInserter[InsertView](db).Values(&InsertView{Id: 1}).Build()
+	assert.Equal(t, errs.NewErrReadOnlyModel("insert_view"), err)
+}
+
+func TestInserter_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_model`(`first_name`,`age`) VALUES (?,?),(?,?);")).
+		WithArgs("Tom", int8(18), "Jerry", int8(20)).
+		WillReturnResult(sqlmock.NewResult(12, 2))
+	mock.ExpectExec("INSERT .*").WillReturnError(errors.New("exec error"))
+
+	res, err := NewInserter[TestModel](db).
+		Values(&TestModel{FirstName: "Tom", Age: 18}, &TestModel{FirstName: "Jerry", Age: 20}).
+		Columns("FirstName", "Age").Exec(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), id)
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	_, err = NewInserter[TestModel](db).Values(&TestModel{}).Exec(context.Background())
+	assert.Equal(t, errors.New("exec error"), err)
+
+	// 构造失败的时候不会执行
+	_, err = NewInserter[TestModel](db).Exec(context.Background())
+	assert.Equal(t, errs.ErrInsertZeroRow, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInserter_Upsert(t *testing.T) {
+	val := &TestModel{
+		Id:        1,
+		FirstName: "Deng",
+		Age:       18,
+	}
+	testCases := []struct {
+		name      string
+		dialect   Dialect
+		q         func(db *DB) QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "mysql value",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).Columns("Id", "Age").
+					OnDuplicateKey().Update(Assign("Age", 19))
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`age`) VALUES (?,?) ON DUPLICATE KEY UPDATE `age`=?;",
+				Args: []any{int64(1), int8(18), 19},
+			},
+		},
+		{
+			name:    "mysql column",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).Columns("Id", "Age").
+					OnDuplicateKey().Update(C("Age"))
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`age`) VALUES (?,?) ON DUPLICATE KEY UPDATE `age`=VALUES(`age`);",
+				Args: []any{int64(1), int8(18)},
+			},
+		},
+		{
+			// 混合使用，参数在 VALUES 的参数之后
+			name:    "mysql mix",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).Columns("Id", "FirstName", "Age").
+					OnDuplicateKey().Update(Assign("FirstName", "Da"), C("Age"), Assign("Id", 2))
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`,`age`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `first_name`=?,`age`=VALUES(`age`),`id`=?;",
+				Args: []any{int64(1), "Deng", int8(18), "Da", 2},
+			},
+		},
+		{
+			name:    "mysql no assignment",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).OnDuplicateKey().Update()
+			},
+			wantErr: errs.ErrUpsertNoAssignment,
+		},
+		{
+			name:    "mysql invalid column",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).Columns("Id").
+					OnDuplicateKey().Update(Assign("Invalid", 1))
+			},
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "sqlite3 mix",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).Columns("Id", "FirstName", "Age").
+					OnDuplicateKey().ConflictColumns("Id").Update(Assign("FirstName", "Da"), C("Age"))
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`,`age`) VALUES (?,?,?) ON CONFLICT(`id`) DO UPDATE SET `first_name`=?,`age`=excluded.`age`;",
+				Args: []any{int64(1), "Deng", int8(18), "Da"},
+			},
+		},
+		{
+			// PostgreSQL 的 DO UPDATE 必须指定冲突的列
+			name:    "postgres no conflict columns",
+			dialect: Postgres,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).Columns("Id", "Age").
+					OnDuplicateKey().Update(C("Age"))
+			},
+			wantErr: errs.ErrUpsertNoConflictColumns,
+		},
+		{
+			name:    "sqlite3 no conflict columns",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).Columns("Id", "Age").
+					OnDuplicateKey().Update(C("Age"))
+			},
+			wantErr: errs.ErrUpsertNoConflictColumns,
+		},
+		{
+			name:    "sqlite3 multiple conflict columns",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).Columns("Id", "FirstName", "Age").
+					OnDuplicateKey().ConflictColumns("Id", "FirstName").Update(C("Age"))
+			},
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`,`age`) VALUES (?,?,?) ON CONFLICT(`id`,`first_name`) DO UPDATE SET `age`=excluded.`age`;",
+				Args: []any{int64(1), "Deng", int8(18)},
+			},
+		},
+		{
+			name:    "postgres",
+			dialect: Postgres,
+			q: func(db *DB) QueryBuilder {
+				return NewInserter[TestModel](db).Values(val).Columns("Id", "Age").
+					OnDuplicateKey().ConflictColumns("Id").Update(C("Age"))
+			},
+			wantQuery: &Query{
+				SQL:  `INSERT INTO "test_model"("id","age") VALUES ($1,$2) ON CONFLICT("id") DO UPDATE SET "age"=excluded."age";`,
+				Args: []any{int64(1), int8(18)},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory",
+				DBWithDialect(tc.dialect))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := tc.q(db).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
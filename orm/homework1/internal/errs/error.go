@@ -3,6 +3,7 @@ package errs
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -13,6 +14,28 @@ var (
 	ErrPointerOnly = errors.New("orm: 只支持一级指针作为输入，例如 *User")
 	ErrNoRows                 = errors.New("orm: 未找到数据")
 	ErrTooManyReturnedColumns = errors.New("eorm: 过多列")
+	// ErrInsertZeroRow 代表插入 0 行
+	ErrInsertZeroRow = errors.New("orm: 插入 0 行")
+	// ErrNoUpdatedColumns 代表 UPDATE 没有指定任何要更新的列
+	ErrNoUpdatedColumns = errors.New("orm: 未指定更新的列")
+	// ErrUpsertNoAssignment 代表 UPSERT 没有指定任何要更新的列
+	ErrUpsertNoAssignment = errors.New("orm: UPSERT 没有指定要更新的列")
+	// ErrUpsertNoConflictColumns 代表使用 ON CONFLICT 的方言没有指定冲突的列
+	ErrUpsertNoConflictColumns = errors.New("orm: UPSERT 没有指定冲突的列")
+	// ErrUnsupportedAggregateFilter 方言不支持 COUNT(*) FILTER (WHERE ...)
+	ErrUnsupportedAggregateFilter = errors.New("orm: 方言不支持聚合函数的 FILTER 子句")
+	// ErrMultipleSoftDeleteFields 代表一个模型声明了多个软删除字段
+	ErrMultipleSoftDeleteFields = errors.New("orm: 只能有一个软删除字段")
+	// ErrNilSelectable 代表 Select 传入了 nil
+	ErrNilSelectable = errors.New("orm: 选择的列不能为 nil")
+	// ErrEmptyTableName 代表 WithTableName 传入了空字符串
+	ErrEmptyTableName = errors.New("orm: 表名不能为空字符串")
+	// ErrEmptyColumn 代表列名是空字符串，一般是写了 C("") 或者 WithColumnName(field, "")
+	ErrEmptyColumn = errors.New("orm: 列名不能为空字符串")
+	// ErrNoSelectedColumns 代表 SelectExcept 排除了全部字段
+	ErrNoSelectedColumns = errors.New("orm: 排除之后没有可以查询的列")
+	// ErrDryRun 代表开启了 dry run，语句只构造不执行
+	ErrDryRun = errors.New("orm: dry run 模式，没有执行语句")
 )
 
 // NewErrUnknownField 返回代表未知字段的错误
@@ -22,6 +45,38 @@ func NewErrUnknownField(fd string) error {
 	return fmt.Errorf("orm: 未知字段 %s", fd)
 }
 
+// NewErrUnknownFields 返回代表多个未知字段的错误，
+// 它合并了每一个字段的 NewErrUnknownField，每个错误占一行
+func NewErrUnknownFields(fds []string) error {
+	res := make(joinError, 0, len(fds))
+	for _, fd := range fds {
+		res = append(res, NewErrUnknownField(fd))
+	}
+	return res
+}
+
+// joinError 合并多个错误，效果和 Go 1.20 的 errors.Join 一样
+type joinError []error
+
+func (e joinError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap 返回被合并的错误，Go 1.20 之后 errors.Is 和 errors.As 会检查每一个错误
+func (e joinError) Unwrap() []error {
+	return e
+}
+
+// NewErrUngroupedColumn 返回代表选择了没有分组的列的错误
+// 一般意味着你忘记了把这个列加入 GROUP BY，或者忘记了使用聚合函数
+func NewErrUngroupedColumn(fd string) error {
+	return fmt.Errorf("orm: 列 %s 既不在 GROUP BY 里面，也没有使用聚合函数", fd)
+}
+
 // NewErrUnknownColumn 返回代表未知列的错误
 // 一般意味着你使用了错误的列名
 // 注意和 NewErrUnknownField 区别
@@ -29,15 +84,59 @@ func NewErrUnknownColumn(col string) error {
 	return fmt.Errorf("orm: 未知列 %s", col)
 }
 
+// NewErrAmbiguousColumn 返回代表 JOIN 查询里面的列有歧义的错误
+// 一般意味着参与 JOIN 的多个表都有这个字段，需要使用 TableOf[T]().C(...) 指定表
+func NewErrAmbiguousColumn(fd string) error {
+	return fmt.Errorf("orm: 列 %s 有歧义，JOIN 的多个表都有这个字段，请使用 TableOf[T]().C(%q) 指定表", fd, fd)
+}
+
+// NewErrDuplicateColumn 返回代表多个字段映射到同一个列的错误
+// 一般意味着标签 column 的值写重复了
+func NewErrDuplicateColumn(col string) error {
+	return fmt.Errorf("orm: 重复的列 %s", col)
+}
+
+// NewErrInvalidIdentifier 返回代表非法标识符的错误
+// 一般是在不使用引号的时候，表名或者列名包含了特殊字符
+func NewErrInvalidIdentifier(name string) error {
+	return fmt.Errorf("orm: 非法的标识符 %s", name)
+}
+
 // NewErrUnsupportedExpressionType 返回一个不支持该 expression 错误信息
 func NewErrUnsupportedExpressionType(exp any) error {
 	return fmt.Errorf("orm: 不支持的表达式 %v", exp)
 }
 
+// NewErrUnsupportedAssignable 返回一个不支持该赋值语句的错误信息
+func NewErrUnsupportedAssignable(a any) error {
+	return fmt.Errorf("orm: 不支持的赋值语句 %v", a)
+}
+
 func NewErrUnsupportedSelectable(exp any) error {
 	return fmt.Errorf("orm: 不支持的目标列 %v", exp)
 }
 
+// NewErrContextDone 执行查询之前 context 就已经结束了，
+// typ 是执行查询的构造器，例如 Selector
+func NewErrContextDone(typ string, err error) error {
+	return fmt.Errorf("orm: %s 执行查询之前 context 已经结束 %w", typ, err)
+}
+
+// NewErrQuery 包装驱动返回的错误，带上操作、表名和 SQL，方便定位是哪个查询出错。
+// 注意参数可能包含敏感信息，所以不能放进去
+func NewErrQuery(typ string, table string, query string, err error) error {
+	return fmt.Errorf("orm: %s %s 执行失败 %w, SQL: %s", typ, table, err, query)
+}
+
+func NewErrFailToRollbackTx(bizErr error, rbErr error, panicked bool) error {
+	return fmt.Errorf("orm: 回滚事务失败, 业务错误 %w, 回滚错误 %s, panic: %t",
+		bizErr, rbErr.Error(), panicked)
+}
+
+func NewErrUnsupportedTableReference(table any) error {
+	return fmt.Errorf("orm: 不支持的表 %v", table)
+}
+
 // 后面可以考虑支持错误码
 // func NewErrUnsupportedExpressionType(exp any) error {
 // 	return fmt.Errorf("orm-50001: 不支持的表达式 %v", exp)
@@ -48,6 +147,58 @@ func NewErrUnsupportedSelectable(exp any) error {
 // 发生该错误，主要是因为传入了不支持的 Expression 的实际类型
 // 一般来说，这是因为中间件
 
+// NewErrReadOnlyModel 返回代表只读模型不能被修改的错误
+func NewErrReadOnlyModel(table string) error {
+	return fmt.Errorf("orm: %s 是只读模型，不支持写操作", table)
+}
+
+// NewErrInvalidSoftDeleteField 返回代表软删除字段类型不合法的错误
+func NewErrInvalidSoftDeleteField(fd string, typ any) error {
+	return fmt.Errorf("orm: 软删除字段 %s 的类型 %v 不合法，只支持 *time.Time 和 sql.NullTime", fd, typ)
+}
+
+// NewErrUnsupportedSetOperation 返回代表方言不支持该集合操作的错误
+func NewErrUnsupportedSetOperation(op string) error {
+	return fmt.Errorf("orm: 方言不支持集合操作 %s", op)
+}
+
+// NewErrUnsupportedLock 返回代表方言不支持该锁定读的错误
+func NewErrUnsupportedLock(lock string) error {
+	return fmt.Errorf("orm: 方言不支持 %s", lock)
+}
+
+// NewErrInvalidLimitOffset 返回代表 LIMIT 或者 OFFSET 的值不合法的错误
+func NewErrInvalidLimitOffset(clause string, val int) error {
+	return fmt.Errorf("orm: 非法的 %s 值 %d", clause, val)
+}
+
+// NewErrInvalidPagination 返回代表分页参数不合法的错误
+func NewErrInvalidPagination(page, size int) error {
+	return fmt.Errorf("orm: 非法的分页参数 page %d, size %d", page, size)
+}
+
 func NewErrInvalidTagContent(tag string) error {
 	return fmt.Errorf("orm: 错误的标签设置: %s", tag)
+}
+
+// NewErrConverterFieldType 返回代表 Converter 不支持字段类型的错误
+// 一般意味着标签 type 用在了错误的字段上，例如 unixtime 只能用于 time.Time
+func NewErrConverterFieldType(name string, fd string, typ any) error {
+	return fmt.Errorf("orm: 转换器 %s 不支持字段 %s 的类型 %v", name, fd, typ)
+}
+
+// NewErrConverterResultType 返回代表 Converter 返回值的类型和字段类型不一致的错误
+func NewErrConverterResultType(fd string, want any, got any) error {
+	return fmt.Errorf("orm: 字段 %s 的转换器返回了类型 %v，期望 %v", fd, got, want)
+}
+
+// NewErrConverterValue 返回代表 Converter 收到了不支持的值的错误
+func NewErrConverterValue(val any) error {
+	return fmt.Errorf("orm: 转换器不支持类型 %T 的值", val)
+}
+
+// NewErrUnknownConverter 返回代表未知 Converter 的错误
+// 一般意味着标签 type 的值写错了，或者忘记注册自定义的 Converter
+func NewErrUnknownConverter(name string) error {
+	return fmt.Errorf("orm: 未知的转换器 %s", name)
 }
\ No newline at end of file
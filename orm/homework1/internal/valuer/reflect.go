@@ -41,6 +41,11 @@ func (r reflectValue) SetColumns(rows *sql.Rows) error {
 		if !ok {
 			return errs.NewErrUnknownColumn(c)
 		}
+		if cm.Converter != nil {
+			// 使用 Converter 的字段，先扫描到 Converter 指定的目标上
+			colValues[i] = cm.Converter.ScanTarget()
+			continue
+		}
 		val := reflect.New(cm.Type)
 		colValues[i] = val.Interface()
 		colEleValues[i] = val.Elem()
@@ -51,7 +56,22 @@ func (r reflectValue) SetColumns(rows *sql.Rows) error {
 	for i, c := range cs {
 		cm := r.meta.ColumnMap[c]
 		fd := r.val.FieldByName(cm.GoName)
+		if cm.Converter != nil {
+			v, err := fromDB(cm, colValues[i])
+			if err != nil {
+				return err
+			}
+			fd.Set(v)
+			continue
+		}
 		fd.Set(colEleValues[i])
 	}
 	return nil
 }
+
+func (r reflectValue) Field(name string) (any, error) {
+	if _, ok := r.meta.FieldMap[name]; !ok {
+		return nil, errs.NewErrUnknownField(name)
+	}
+	return r.val.FieldByName(name).Interface(), nil
+}
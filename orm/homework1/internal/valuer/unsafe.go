@@ -32,14 +32,47 @@ func (u unsafeValue) SetColumns(rows *sql.Rows) error {
 	}
 
 	colValues := make([]interface{}, len(cs))
+	// 使用 Converter 的字段，在 Scan 之后还需要转换
+	var converted []*model.Field
 	for i, c := range cs {
 		cm, ok := u.meta.ColumnMap[c]
 		if !ok {
 			return errs.NewErrUnknownColumn(c)
 		}
+		if cm.Converter != nil {
+			if converted == nil {
+				converted = make([]*model.Field, len(cs))
+			}
+			converted[i] = cm
+			colValues[i] = cm.Converter.ScanTarget()
+			continue
+		}
 		ptr := unsafe.Pointer(uintptr(u.addr) + cm.Offset)
 		val := reflect.NewAt(cm.Type, ptr)
 		colValues[i] = val.Interface()
 	}
-	return rows.Scan(colValues...)
+	if err = rows.Scan(colValues...); err != nil {
+		return err
+	}
+	for i, cm := range converted {
+		if cm == nil {
+			continue
+		}
+		v, err := fromDB(cm, colValues[i])
+		if err != nil {
+			return err
+		}
+		ptr := unsafe.Pointer(uintptr(u.addr) + cm.Offset)
+		reflect.NewAt(cm.Type, ptr).Elem().Set(v)
+	}
+	return nil
+}
+
+func (u unsafeValue) Field(name string) (any, error) {
+	fd, ok := u.meta.FieldMap[name]
+	if !ok {
+		return nil, errs.NewErrUnknownField(name)
+	}
+	ptr := unsafe.Pointer(uintptr(u.addr) + fd.Offset)
+	return reflect.NewAt(fd.Type, ptr).Elem().Interface(), nil
 }
@@ -2,17 +2,34 @@ package valuer
 
 import (
 	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
 	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+	"reflect"
 )
 
 // Value 是对结构体实例的内部抽象
 type Value interface {
 	// SetColumns 设置新值
 	SetColumns(rows *sql.Rows) error
+	// Field 读取字段的值，name 是字段名
+	Field(name string) (any, error)
 }
 
 type Creator func(val interface{}, meta *model.Model) Value
 
+// fromDB 使用字段的 Converter 转换 Scan 之后的 target，
+// 返回值的类型必须和字段的类型一致，否则 Set 的时候会 panic
+func fromDB(fd *model.Field, target any) (reflect.Value, error) {
+	v, err := fd.Converter.FromDB(target)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if typ := reflect.TypeOf(v); typ != fd.Type {
+		return reflect.Value{}, errs.NewErrConverterResultType(fd.GoName, fd.Type, typ)
+	}
+	return reflect.ValueOf(v), nil
+}
+
 // ResultSetHandler 这是另外一种可行的设计方案
 // type ResultSetHandler interface {
 // 	// SetColumns 设置新值，column 是列名
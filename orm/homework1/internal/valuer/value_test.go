@@ -0,0 +1,321 @@
+package valuer
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValue_SetColumnsWithConverter(t *testing.T) {
+	type UnixTimeModel struct {
+		Id        int64
+		CreatedAt time.Time `orm:"column=created_at;type=unixtime"`
+	}
+	creators := map[string]Creator{
+		"reflect": NewReflectValue,
+		"unsafe":  NewUnsafeValue,
+	}
+	testCases := []struct {
+		name    string
+		ts      any
+		wantVal *UnixTimeModel
+	}{
+		{
+			name: "unix time",
+			ts:   int64(1665331200),
+			wantVal: &UnixTimeModel{
+				Id:        1,
+				CreatedAt: time.Unix(1665331200, 0),
+			},
+		},
+		{
+			// NULL 转换为零值
+			name:    "null",
+			ts:      nil,
+			wantVal: &UnixTimeModel{Id: 1},
+		},
+	}
+
+	r := model.NewRegistry()
+	meta, err := r.Get(&UnixTimeModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, creator := range creators {
+		for _, tc := range testCases {
+			t.Run(name+" "+tc.name, func(t *testing.T) {
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = db.Close() }()
+				mock.ExpectQuery("SELECT *").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).
+						AddRow(int64(1), tc.ts))
+				rows, _ := db.Query("SELECT *")
+				rows.Next()
+				val := &UnixTimeModel{}
+				err = creator(val, meta).SetColumns(rows)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tc.wantVal, val)
+			})
+		}
+	}
+}
+
+func TestValue_Field(t *testing.T) {
+	type FieldModel struct {
+		Id    int64
+		Name  string
+		Age   *int8
+		Bytes []byte
+	}
+	age := int8(18)
+	val := &FieldModel{
+		Id:    1,
+		Name:  "Tom",
+		Age:   &age,
+		Bytes: []byte("hello"),
+	}
+	meta, err := model.NewRegistry().Get(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creators := map[string]Creator{
+		"reflect": NewReflectValue,
+		"unsafe":  NewUnsafeValue,
+	}
+	for name, creator := range creators {
+		t.Run(name, func(t *testing.T) {
+			v := creator(val, meta)
+			for fd, want := range map[string]any{
+				"Id":    int64(1),
+				"Name":  "Tom",
+				"Age":   &age,
+				"Bytes": []byte("hello"),
+			} {
+				res, err := v.Field(fd)
+				assert.NoError(t, err)
+				assert.Equal(t, want, res)
+			}
+			_, err := v.Field("Invalid")
+			assert.Equal(t, errs.NewErrUnknownField("Invalid"), err)
+		})
+	}
+}
+
+// resultConverter 返回固定的值，用于模拟有问题的 Converter
+type resultConverter struct {
+	val any
+}
+
+func (resultConverter) ScanTarget() any {
+	return &sql.NullInt64{}
+}
+
+func (c resultConverter) FromDB(target any) (any, error) {
+	return c.val, nil
+}
+
+func (c resultConverter) ToDB(val any) (any, error) {
+	return val, nil
+}
+
+func TestValue_SetColumnsConverterResult(t *testing.T) {
+	type ResultModel struct {
+		Id        int64
+		CreatedAt time.Time `orm:"type=result"`
+	}
+	creators := map[string]Creator{
+		"reflect": NewReflectValue,
+		"unsafe":  NewUnsafeValue,
+	}
+	testCases := []struct {
+		name    string
+		val     any
+		wantErr error
+	}{
+		{
+			name:    "nil result",
+			val:     nil,
+			wantErr: errs.NewErrConverterResultType("CreatedAt", reflect.TypeOf(time.Time{}), nil),
+		},
+		{
+			name: "mismatched result",
+			val:  int64(1665331200),
+			wantErr: errs.NewErrConverterResultType("CreatedAt",
+				reflect.TypeOf(time.Time{}), reflect.TypeOf(int64(0))),
+		},
+	}
+	for name, creator := range creators {
+		for _, tc := range testCases {
+			t.Run(name+" "+tc.name, func(t *testing.T) {
+				r := model.NewRegistry(model.RegistryWithConverter("result", resultConverter{val: tc.val}))
+				meta, err := r.Get(&ResultModel{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = db.Close() }()
+				mock.ExpectQuery("SELECT *").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).
+						AddRow(int64(1), int64(1665331200)))
+				rows, _ := db.Query("SELECT *")
+				rows.Next()
+				err = creator(&ResultModel{}, meta).SetColumns(rows)
+				assert.Equal(t, tc.wantErr, err)
+				_ = rows.Close()
+			})
+		}
+	}
+}
+
+func TestValue_SetColumnsBasicTypes(t *testing.T) {
+	type BasicModel struct {
+		Id        int64
+		Name      string
+		Active    bool
+		CreatedAt time.Time
+		Score     *int
+		Nickname  *string
+		Verified  *bool
+		DeletedAt *time.Time
+	}
+	creators := map[string]Creator{
+		"reflect": NewReflectValue,
+		"unsafe":  NewUnsafeValue,
+	}
+	now := time.Date(2022, 10, 10, 8, 0, 0, 0, time.UTC)
+	score, nickname, verified := 90, "Tom", true
+	cols := []string{"id", "name", "active", "created_at", "score", "nickname", "verified", "deleted_at"}
+	testCases := []struct {
+		name    string
+		row     []driver.Value
+		wantVal *BasicModel
+	}{
+		{
+			name: "all set",
+			row:  []driver.Value{int64(1), "Tom", true, now, int64(90), "Tom", true, now},
+			wantVal: &BasicModel{
+				Id:        1,
+				Name:      "Tom",
+				Active:    true,
+				CreatedAt: now,
+				Score:     &score,
+				Nickname:  &nickname,
+				Verified:  &verified,
+				DeletedAt: &now,
+			},
+		},
+		{
+			// 指针类型的字段 NULL 转为 nil
+			name: "null pointers",
+			row:  []driver.Value{int64(2), "Jerry", false, now, nil, nil, nil, nil},
+			wantVal: &BasicModel{
+				Id:        2,
+				Name:      "Jerry",
+				CreatedAt: now,
+			},
+		},
+	}
+
+	r := model.NewRegistry()
+	meta, err := r.Get(&BasicModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, creator := range creators {
+		for _, tc := range testCases {
+			t.Run(name+" "+tc.name, func(t *testing.T) {
+				db, mock, err := sqlmock.New()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = db.Close() }()
+				mock.ExpectQuery("SELECT *").
+					WillReturnRows(sqlmock.NewRows(cols).AddRow(tc.row...))
+				rows, _ := db.Query("SELECT *")
+				rows.Next()
+				val := &BasicModel{}
+				err = creator(val, meta).SetColumns(rows)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tc.wantVal, val)
+				_ = rows.Close()
+			})
+		}
+	}
+}
+
+// BenchmarkCreator 对比反射和 unsafe 两种实现读取一行 10 个列的性能
+func BenchmarkCreator(b *testing.B) {
+	type BenchModel struct {
+		Id        int64
+		Name      string
+		Age       int8
+		Email     string
+		Phone     string
+		Address   string
+		Score     float64
+		Active    bool
+		CreatedAt int64
+		UpdatedAt int64
+	}
+	db, err := sql.Open("sqlite3", "file:benchmark_creator.db?cache=shared&mode=memory")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS `bench_model`(" +
+		"`id` INTEGER PRIMARY KEY, `name` TEXT, `age` INTEGER, `email` TEXT, `phone` TEXT," +
+		"`address` TEXT, `score` REAL, `active` BOOLEAN, `created_at` INTEGER, `updated_at` INTEGER)")
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, err = db.Exec("INSERT OR REPLACE INTO `bench_model` VALUES (?,?,?,?,?,?,?,?,?,?)",
+		1, "Tom", 18, "tom@example.com", "12345678", "Shanghai", 99.5, true, 1665331200, 1665331200)
+	if err != nil {
+		b.Fatal(err)
+	}
+	meta, err := model.NewRegistry().Get(&BenchModel{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	creators := []struct {
+		name    string
+		creator Creator
+	}{
+		{name: "reflect", creator: NewReflectValue},
+		{name: "unsafe", creator: NewUnsafeValue},
+	}
+	for _, c := range creators {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				rows, err := db.Query("SELECT * FROM `bench_model`")
+				if err != nil {
+					b.Fatal(err)
+				}
+				if !rows.Next() {
+					b.Fatal("没有数据")
+				}
+				if err = c.creator(&BenchModel{}, meta).SetColumns(rows); err != nil {
+					b.Fatal(err)
+				}
+				_ = rows.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package orm
+
+import (
+	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+)
+
+// QueryContext 是中间件可以拿到的查询上下文
+type QueryContext struct {
+	// Type 声明查询类型。即 SELECT, UPDATE, DELETE, INSERT 和 RAW
+	Type string
+	// Query 是构造好的查询，中间件可以修改它
+	Query *Query
+	// Model 是查询对应的元数据，RAW 查询的时候是结果类型的元数据
+	Model *model.Model
+}
+
+type QueryResult struct {
+	// Result 在不同的查询里面，类型是不同的
+	// Selector.Get 里面，这会是单个结果
+	// Selector.GetMulti，这会是一个切片
+	// Selector.Iter 里面，这会是 *sql.Rows
+	// 其它情况下，它会是 sql.Result 类型
+	Result any
+	Err    error
+}
+
+type Handler func(ctx context.Context, qc *QueryContext) *QueryResult
+
+// Middleware 例如：
+//
+//	func(next Handler) Handler {
+//		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+//			log.Println(qc.Query.SQL)
+//			return next(ctx, qc)
+//		}
+//	}
+type Middleware func(next Handler) Handler
+
+// handle 将 root 用中间件包起来再执行，root 负责真正发起查询。
+// 先注册的中间件在最外层
+func (db *DB) handle(ctx context.Context, qc *QueryContext, root Handler) *QueryResult {
+	handler := root
+	if db.dryRun {
+		handler = dryRunHandler
+	}
+	for i := len(db.ms) - 1; i >= 0; i-- {
+		handler = db.ms[i](handler)
+	}
+	return handler(ctx, qc)
+}
+
+// dryRunHandler 代替真正发起查询的 Handler，直接返回构造好的查询
+func dryRunHandler(_ context.Context, qc *QueryContext) *QueryResult {
+	return &QueryResult{Err: &DryRunError{Query: qc.Query}}
+}
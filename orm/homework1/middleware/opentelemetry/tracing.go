@@ -0,0 +1,54 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultInstrumentationName = "gitee.com/geektime-geekbang/geektime-go/orm/homework1/middleware/opentelemetry"
+
+// MiddlewareBuilder 构造链路追踪的中间件，每一个查询都会创建一个 span。
+// span 的起止时间就是查询的耗时
+type MiddlewareBuilder struct {
+	// Tracer 为 nil 的时候使用全局的 TracerProvider
+	Tracer trace.Tracer
+}
+
+func (b MiddlewareBuilder) Build() orm.Middleware {
+	if b.Tracer == nil {
+		b.Tracer = otel.GetTracerProvider().Tracer(defaultInstrumentationName)
+	}
+	return func(next orm.Handler) orm.Handler {
+		return func(ctx context.Context, qc *orm.QueryContext) *orm.QueryResult {
+			var tbl string
+			if qc.Model != nil {
+				tbl = qc.Model.TableName
+			}
+			// span 的名字只用操作和表名，不能用 SQL，否则取值太多了
+			spanCtx, span := b.Tracer.Start(ctx, qc.Type+" "+tbl,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("component", "orm"),
+					attribute.String("db.operation", qc.Type),
+					attribute.String("db.sql.table", tbl),
+				))
+			defer span.End()
+			// 只记录 SQL，参数可能包含敏感信息
+			if qc.Query != nil {
+				span.SetAttributes(attribute.String("db.statement", qc.Query.SQL))
+			}
+			res := next(spanCtx, qc)
+			// 没有数据是正常的业务结果，不算失败
+			if res.Err != nil && !errors.Is(res.Err, orm.ErrNoRows) {
+				span.RecordError(res.Err)
+				span.SetStatus(codes.Error, res.Err.Error())
+			}
+			return res
+		}
+	}
+}
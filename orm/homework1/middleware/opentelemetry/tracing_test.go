@@ -0,0 +1,66 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+type TestModel struct {
+	Id        int64
+	FirstName string
+}
+
+func TestMiddlewareBuilder_Build(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := tp.Tracer("test")
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := orm.OpenDB(mockDB, orm.DBWithMiddlewares(MiddlewareBuilder{Tracer: tracer}.Build()))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT .*").WithArgs(2).
+		WillReturnError(errors.New("mock error"))
+	mock.ExpectQuery("SELECT .*").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx, parent := tracer.Start(context.Background(), "biz")
+	_, err = orm.NewSelector[TestModel](db).Where(orm.C("Id").EQ(1)).Get(ctx)
+	require.NoError(t, err)
+	_, err = orm.NewSelector[TestModel](db).Where(orm.C("Id").EQ(2)).Get(ctx)
+	require.Error(t, err)
+	_, err = orm.NewSelector[TestModel](db).Where(orm.C("Id").EQ(3)).Get(ctx)
+	require.Equal(t, orm.ErrNoRows, err)
+	parent.End()
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	spans := recorder.Ended()
+	// 三个查询加上业务的 span
+	require.Len(t, spans, 4)
+	wantStatus := []codes.Code{codes.Unset, codes.Error, codes.Unset}
+	for i, span := range spans[:3] {
+		assert.Equal(t, "SELECT test_model", span.Name())
+		// 嵌套在调用者的 span 下面
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		assert.Contains(t, span.Attributes(), attribute.String("db.sql.table", "test_model"))
+		assert.Contains(t, span.Attributes(), attribute.String("db.operation", "SELECT"))
+		assert.Contains(t, span.Attributes(),
+			attribute.String("db.statement", "SELECT * FROM `test_model` WHERE `id` = ?;"))
+		assert.False(t, span.EndTime().Before(span.StartTime()))
+		assert.Equal(t, wantStatus[i], span.Status().Code)
+	}
+	assert.Contains(t, spans[1].Status().Description, "mock error")
+}
@@ -0,0 +1,145 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	var (
+		sqls  []string
+		types []string
+		order []string
+	)
+	// 记录执行的 SQL
+	recordSQL := func(next Handler) Handler {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			sqls = append(sqls, qc.Query.SQL)
+			types = append(types, qc.Type)
+			return next(ctx, qc)
+		}
+	}
+	mark := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, qc *QueryContext) *QueryResult {
+				order = append(order, name+" before")
+				res := next(ctx, qc)
+				order = append(order, name+" after")
+				return res
+			}
+		}
+	}
+	db, err := OpenDB(mockDB, DBWithMiddlewares(recordSQL, mark("first"), mark("second")))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	res, err := NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1}, res)
+
+	mock.ExpectExec("DELETE .*").WillReturnResult(sqlmock.NewResult(0, 3))
+	result, err := NewDeleter[TestModel](db).Where(C("Age").GT(18)).Exec(context.Background())
+	require.NoError(t, err)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+
+	assert.Equal(t, []string{
+		"SELECT * FROM `test_model` WHERE `id` = ?;",
+		"DELETE FROM `test_model` WHERE `age` > ?;",
+	}, sqls)
+	assert.Equal(t, []string{"SELECT", "DELETE"}, types)
+	// 先注册的中间件在外层
+	assert.Equal(t, []string{
+		"first before", "second before", "second after", "first after",
+		"first before", "second before", "second after", "first after",
+	}, order)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddleware_ShortCircuit(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	// 不调用 next，直接返回结果，例如缓存
+	cache := func(next Handler) Handler {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			if qc.Model.TableName == "test_model" {
+				return &QueryResult{Result: []*TestModel{{Id: 12}}}
+			}
+			return next(ctx, qc)
+		}
+	}
+	db, err := OpenDB(mockDB, DBWithMiddlewares(cache))
+	require.NoError(t, err)
+
+	res, err := NewSelector[TestModel](db).GetMulti(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{{Id: 12}}, res)
+	// 没有发起任何查询
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMiddleware_Iter(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	var (
+		sqls []string
+		ctxs []context.Context
+	)
+	db, err := OpenDB(mockDB, DBWithQueryTimeout(time.Minute),
+		DBWithMiddlewares(func(next Handler) Handler {
+			return func(ctx context.Context, qc *QueryContext) *QueryResult {
+				sqls = append(sqls, qc.Query.SQL)
+				ctxs = append(ctxs, ctx)
+				return next(ctx, qc)
+			}
+		}))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	iter, err := NewSelector[TestModel](db).Iter(context.Background())
+	require.NoError(t, err)
+	var res []*TestModel
+	for iter.Next() {
+		tm, err := iter.Scan()
+		require.NoError(t, err)
+		res = append(res, tm)
+	}
+	require.NoError(t, iter.Err())
+	assert.Equal(t, []*TestModel{{Id: 1}, {Id: 2}}, res)
+	assert.Equal(t, []string{"SELECT * FROM `test_model`;"}, sqls)
+
+	// 默认的超时时间在 Close 之前一直有效
+	require.Len(t, ctxs, 1)
+	_, ok := ctxs[0].Deadline()
+	assert.True(t, ok)
+	assert.NoError(t, ctxs[0].Err())
+	require.NoError(t, iter.Close())
+	assert.Equal(t, context.Canceled, ctxs[0].Err())
+	assert.NoError(t, iter.Close())
+
+	// 查询失败的时候也经过中间件
+	mock.ExpectQuery("SELECT .*").WillReturnError(errors.New("mock error"))
+	_, err = NewSelector[TestModel](db).Iter(context.Background())
+	assert.Equal(t, errs.NewErrQuery("SELECT", "test_model", "SELECT * FROM `test_model`;",
+		errors.New("mock error")), err)
+	assert.Len(t, sqls, 2)
+	assert.Equal(t, context.Canceled, ctxs[1].Err())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"reflect"
+	"time"
+)
+
+// Converter 用于在数据库的值和字段的值之间进行转换
+// 通过标签 orm:"type=xxx" 指定字段使用哪个 Converter
+type Converter interface {
+	// ScanTarget 返回一个新的用于 Scan 的目标，必须是指针
+	ScanTarget() any
+	// FromDB 将 Scan 之后的 ScanTarget 转换为字段的值
+	FromDB(target any) (any, error)
+	// ToDB 将字段的值转换为写入数据库的值
+	ToDB(val any) (any, error)
+}
+
+// FieldTyper 是 Converter 可选实现的接口，声明它只能用于哪种类型的字段，
+// 注册模型的时候会校验，避免在处理结果集的时候才发现用错了字段
+type FieldTyper interface {
+	FieldType() reflect.Type
+}
+
+// builtinConverters 内置的 Converter
+var builtinConverters = map[string]Converter{
+	"unixtime": UnixTimeConverter{},
+}
+
+// UnixTimeConverter 将以秒为单位的 Unix 时间戳转换为 time.Time
+// NULL 会被转换为 time.Time 的零值
+type UnixTimeConverter struct{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (UnixTimeConverter) FieldType() reflect.Type {
+	return timeType
+}
+
+func (UnixTimeConverter) ScanTarget() any {
+	return &sql.NullInt64{}
+}
+
+func (UnixTimeConverter) FromDB(target any) (any, error) {
+	ts, ok := target.(*sql.NullInt64)
+	if !ok {
+		return nil, errs.NewErrConverterValue(target)
+	}
+	if !ts.Valid {
+		return time.Time{}, nil
+	}
+	return time.Unix(ts.Int64, 0), nil
+}
+
+func (UnixTimeConverter) ToDB(val any) (any, error) {
+	t, ok := val.(time.Time)
+	if !ok {
+		return nil, errs.NewErrConverterValue(val)
+	}
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.Unix(), nil
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUnixTimeConverter_FromDB(t *testing.T) {
+	testCases := []struct {
+		name    string
+		target  any
+		wantVal any
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			target:  &sql.NullInt64{Int64: 1665331200, Valid: true},
+			wantVal: time.Unix(1665331200, 0),
+		},
+		{
+			name:    "null",
+			target:  &sql.NullInt64{},
+			wantVal: time.Time{},
+		},
+		{
+			name:    "invalid target",
+			target:  &sql.NullString{},
+			wantErr: errs.NewErrConverterValue(&sql.NullString{}),
+		},
+		{
+			name:    "nil",
+			target:  nil,
+			wantErr: errs.NewErrConverterValue(nil),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			val, err := UnixTimeConverter{}.FromDB(tc.target)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestUnixTimeConverter_ToDB(t *testing.T) {
+	testCases := []struct {
+		name    string
+		val     any
+		wantVal any
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			val:     time.Unix(1665331200, 0),
+			wantVal: int64(1665331200),
+		},
+		{
+			// 零值写入 NULL
+			name: "zero",
+			val:  time.Time{},
+		},
+		{
+			name:    "invalid value",
+			val:     int64(1665331200),
+			wantErr: errs.NewErrConverterValue(int64(1665331200)),
+		},
+		{
+			name:    "pointer",
+			val:     &time.Time{},
+			wantErr: errs.NewErrConverterValue(&time.Time{}),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			val, err := UnixTimeConverter{}.ToDB(tc.val)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
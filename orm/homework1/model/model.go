@@ -9,6 +9,13 @@ type Model struct {
 	TableName string
 	FieldMap  map[string]*Field
 	ColumnMap map[string]*Field
+	// Fields 按照字段定义的顺序排列，用于生成确定的 SQL，例如 INSERT 语句
+	Fields []*Field
+	// ReadOnly 只读模型，例如视图，只能用于查询
+	ReadOnly bool
+	// SoftDelete 是软删除字段，为 nil 说明没有开启软删除。
+	// 开启之后，查询会过滤掉已经删除的数据，删除则变成设置这个字段
+	SoftDelete *Field
 }
 
 // Field 字段
@@ -18,14 +25,25 @@ type Field struct {
 	Type   reflect.Type
 	// Offset 相对于对象起始地址的字段偏移量
 	Offset uintptr
+	// Converter 不为 nil 的时候，读写该字段都要经过它转换
+	Converter Converter
 }
 
 // 我们支持的全部标签上的 key 都放在这里
 // 方便用户查找，和我们后期维护
 const (
 	tagKeyColumn = "column"
+	// tagKeyType 指定字段使用的 Converter
+	tagKeyType = "type"
+	// tagKeySoftDelete 将字段标记为软删除字段，它不需要值，例如 orm:"soft_delete"
+	tagKeySoftDelete = "soft_delete"
 )
 
+// flagTagKeys 是不需要值的 key
+var flagTagKeys = map[string]struct{}{
+	tagKeySoftDelete: {},
+}
+
 // 用户自定义一些模型信息的接口，集中放在这里
 // 方便用户查找和我们后期维护
 
@@ -1,15 +1,20 @@
 package model
 
 import (
+	"database/sql"
 	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 )
 
 type Option func(m *Model) error
 
+// RegistryOption 用于配置 registry
+type RegistryOption func(r *registry)
+
 // Registry 元数据注册中心的抽象
 type Registry interface {
 	// Get 查找元数据
@@ -22,20 +27,52 @@ type Registry interface {
 // 目前来看，我们只有一个实现，所以暂时可以维持私有
 type registry struct {
 	models sync.Map
+	// converters 用户自定义的 Converter，会覆盖同名的内置 Converter
+	converters map[string]Converter
+}
+
+func NewRegistry(opts ...RegistryOption) Registry {
+	res := &registry{}
+	for _, opt := range opts {
+		opt(res)
+	}
+	return res
+}
+
+// RegistryWithConverter 注册自定义的 Converter，
+// 之后就可以在标签中通过 orm:"type=name" 来使用
+func RegistryWithConverter(name string, c Converter) RegistryOption {
+	return func(r *registry) {
+		if r.converters == nil {
+			r.converters = make(map[string]Converter, 4)
+		}
+		r.converters[name] = c
+	}
 }
 
-func NewRegistry() Registry {
-	return &registry{}
+func (r *registry) converter(name string) (Converter, bool) {
+	if c, ok := r.converters[name]; ok {
+		return c, true
+	}
+	c, ok := builtinConverters[name]
+	return c, ok
 }
 
-// Get 查找元数据模型
+// Get 查找元数据模型，每个类型只会被缓存一次。
+// 多个 goroutine 同时解析同一个类型的时候，
+// 使用 LoadOrStore 保证它们拿到的是同一个 Model
 func (r *registry) Get(val any) (*Model, error) {
 	typ := reflect.TypeOf(val)
 	m, ok := r.models.Load(typ)
 	if ok {
 		return m.(*Model), nil
 	}
-	return r.Register(val)
+	res, err := r.parseModel(val)
+	if err != nil {
+		return nil, err
+	}
+	m, _ = r.models.LoadOrStore(typ, res)
+	return m.(*Model), nil
 }
 
 func (r *registry) Register(val any, opts ...Option) (*Model, error) {
@@ -55,7 +92,7 @@ func (r *registry) Register(val any, opts ...Option) (*Model, error) {
 }
 
 // parseModel 支持从标签中提取自定义设置
-// 标签形式 orm:"key1=value1,key2=value2"
+// 标签形式 orm:"key1=value1,key2=value2"，也可以用分号分隔 orm:"key1=value1;key2=value2"
 func (r *registry) parseModel(val any) (*Model, error) {
 	typ := reflect.TypeOf(val)
 	if typ.Kind() != reflect.Ptr ||
@@ -68,6 +105,8 @@ func (r *registry) parseModel(val any) (*Model, error) {
 	numField := typ.NumField()
 	fds := make(map[string]*Field, numField)
 	colMap := make(map[string]*Field, numField)
+	fields := make([]*Field, 0, numField)
+	var softDelete *Field
 	for i := 0; i < numField; i++ {
 		fdType := typ.Field(i)
 		tags, err := r.parseTag(fdType.Tag)
@@ -78,14 +117,38 @@ func (r *registry) parseModel(val any) (*Model, error) {
 		if colName == "" {
 			colName = underscoreName(fdType.Name)
 		}
+		// 两个字段映射到同一个列，查询和结果集处理都会出问题
+		if _, ok := colMap[colName]; ok {
+			return nil, errs.NewErrDuplicateColumn(colName)
+		}
 		f := &Field{
 			ColName: colName,
 			Type:    fdType.Type,
 			GoName:  fdType.Name,
 			Offset:  fdType.Offset,
 		}
+		if typName := tags[tagKeyType]; typName != "" {
+			c, ok := r.converter(typName)
+			if !ok {
+				return nil, errs.NewErrUnknownConverter(typName)
+			}
+			if ft, ok := c.(FieldTyper); ok && ft.FieldType() != fdType.Type {
+				return nil, errs.NewErrConverterFieldType(typName, fdType.Name, fdType.Type)
+			}
+			f.Converter = c
+		}
+		if _, ok := tags[tagKeySoftDelete]; ok {
+			if softDelete != nil {
+				return nil, errs.ErrMultipleSoftDeleteFields
+			}
+			if err = checkSoftDelete(f); err != nil {
+				return nil, err
+			}
+			softDelete = f
+		}
 		fds[fdType.Name] = f
 		colMap[colName] = f
+		fields = append(fields, f)
 	}
 	var tableName string
 	if tn, ok := val.(TableName); ok {
@@ -97,9 +160,11 @@ func (r *registry) parseModel(val any) (*Model, error) {
 	}
 
 	return &Model{
-		TableName: tableName,
-		FieldMap:  fds,
-		ColumnMap: colMap,
+		TableName:  tableName,
+		FieldMap:   fds,
+		ColumnMap:  colMap,
+		Fields:     fields,
+		SoftDelete: softDelete,
 	}, nil
 }
 
@@ -114,9 +179,13 @@ func (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 	res := make(map[string]string, 1)
 
 	// 接下来就是字符串处理了
-	pairs := strings.Split(ormTag, ",")
+	pairs := strings.Split(strings.ReplaceAll(ormTag, ";", ","), ",")
 	for _, pair := range pairs {
 		kv := strings.Split(pair, "=")
+		if _, ok := flagTagKeys[pair]; ok && len(kv) == 1 {
+			res[pair] = ""
+			continue
+		}
 		if len(kv) != 2 {
 			return nil, errs.NewErrInvalidTagContent(pair)
 		}
@@ -127,38 +196,102 @@ func (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 
 // underscoreName 驼峰转字符串命名
 func underscoreName(tableName string) string {
-	var buf []byte
-	for i, v := range tableName {
-		if unicode.IsUpper(v) {
-			if i != 0 {
+	runes := []rune(tableName)
+	buf := make([]rune, 0, len(runes)+4)
+	for i, v := range runes {
+		if !unicode.IsUpper(v) {
+			buf = append(buf, v)
+			continue
+		}
+		// 连续的大写字母是一个缩写，例如 HTTPCode 里面的 HTTP，
+		// 所以只在小写字母或者数字之后，以及缩写的最后一个字母之前加下划线。
+		// 缩写后面单独的 s 是复数，例如 UserIDs 里面的 IDs，不会被分开
+		if i > 0 && runes[i-1] != '_' {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && !isPluralSuffix(runes, i+1)) {
 				buf = append(buf, '_')
 			}
-			buf = append(buf, byte(unicode.ToLower(v)))
-		} else {
-			buf = append(buf, byte(v))
 		}
-
+		buf = append(buf, unicode.ToLower(v))
 	}
 	return string(buf)
 }
 
+// isPluralSuffix 判断 runes[i] 是不是缩写后面表示复数的 s，
+// 也就是说它后面要么没有字符了，要么不是小写字母
+func isPluralSuffix(runes []rune, i int) bool {
+	return runes[i] == 's' && (i+1 == len(runes) || !unicode.IsLower(runes[i+1]))
+}
+
+// WithTableName 覆盖默认的表名，一般用于表名和结构体名对不上的旧表。
+// 注册之后所有的语句都会使用这个表名，除非在语句里面通过 From 指定了别的表
 func WithTableName(tableName string) Option {
 	return func(model *Model) error {
+		if tableName == "" {
+			return errs.ErrEmptyTableName
+		}
 		model.TableName = tableName
 		return nil
 	}
 }
 
+// WithReadOnly 将模型标记为只读，一般用于视图。
+// 只读模型只能用于 SELECT，不能用于 INSERT, UPDATE 和 DELETE
+func WithReadOnly() Option {
+	return func(model *Model) error {
+		model.ReadOnly = true
+		return nil
+	}
+}
+
+// WithSoftDelete 将 field 作为软删除字段，和标签 orm:"soft_delete" 的效果一样。
+// 软删除字段必须是可以为 NULL 的时间，也就是 *time.Time 或者 sql.NullTime
+func WithSoftDelete(field string) Option {
+	return func(model *Model) error {
+		fd, ok := model.FieldMap[field]
+		if !ok {
+			return errs.NewErrUnknownField(field)
+		}
+		if err := checkSoftDelete(fd); err != nil {
+			return err
+		}
+		model.SoftDelete = fd
+		return nil
+	}
+}
+
+var (
+	timePtrType  = reflect.TypeOf(&time.Time{})
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+// checkSoftDelete 软删除依赖于 IS NULL 判断数据有没有被删除，
+// 所以字段必须能够表达 NULL
+func checkSoftDelete(fd *Field) error {
+	if fd.Type != timePtrType && fd.Type != nullTimeType {
+		return errs.NewErrInvalidSoftDeleteField(fd.GoName, fd.Type)
+	}
+	return nil
+}
+
+// WithColumnName 将字段 field 映射到列 columnName，和标签 orm:"column=xxx" 的效果一样。
+// 构造 SQL 和处理结果集都会使用新的列名
 func WithColumnName(field string, columnName string) Option {
 	return func(model *Model) error {
 		fd, ok := model.FieldMap[field]
 		if !ok {
 			return errs.NewErrUnknownField(field)
 		}
-		// 注意，这里我们根本没有检测 ColName 会不会是空字符串
-		// 因为正常情况下，用户都不会写错
-		// 即便写错了，也很容易在测试中发现
+		if columnName == "" {
+			return errs.ErrEmptyColumn
+		}
+		if other, ok := model.ColumnMap[columnName]; ok && other != fd {
+			return errs.NewErrDuplicateColumn(columnName)
+		}
+		delete(model.ColumnMap, fd.ColName)
 		fd.ColName = columnName
+		model.ColumnMap[columnName] = fd
 		return nil
 	}
 }
@@ -6,7 +6,9 @@ import (
 	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
 	"github.com/stretchr/testify/assert"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestModelWithTableName(t *testing.T) {
@@ -18,11 +20,10 @@ func TestModelWithTableName(t *testing.T) {
 		wantErr       error
 	}{
 		{
-			// 我们没有对空字符串进行校验
-			name:          "empty string",
-			val:           &TestModel{},
-			opt:           WithTableName(""),
-			wantTableName: "",
+			name:    "empty string",
+			val:     &TestModel{},
+			opt:     WithTableName(""),
+			wantErr: errs.ErrEmptyTableName,
 		},
 		{
 			name:          "table name",
@@ -62,11 +63,11 @@ func TestWithColumnName(t *testing.T) {
 			wantColName: "first_name_new",
 		},
 		{
-			name:        "empty new name",
-			val:         &TestModel{},
-			opt:         WithColumnName("FirstName", ""),
-			field:       "FirstName",
-			wantColName: "",
+			name:    "empty new name",
+			val:     &TestModel{},
+			opt:     WithColumnName("FirstName", ""),
+			field:   "FirstName",
+			wantErr: errs.ErrEmptyColumn,
 		},
 		{
 			// 不存在的字段
@@ -76,6 +77,21 @@ func TestWithColumnName(t *testing.T) {
 			field:   "FirstNameXXX",
 			wantErr: errs.NewErrUnknownField("FirstNameXXX"),
 		},
+		{
+			// 新的列名已经被别的字段占用了
+			name:    "duplicate column",
+			val:     &TestModel{},
+			opt:     WithColumnName("FirstName", "age"),
+			field:   "FirstName",
+			wantErr: errs.NewErrDuplicateColumn("age"),
+		},
+		{
+			name:        "same name",
+			val:         &TestModel{},
+			opt:         WithColumnName("FirstName", "first_name"),
+			field:       "FirstName",
+			wantColName: "first_name",
+		},
 	}
 
 	r := NewRegistry().(*registry)
@@ -88,10 +104,154 @@ func TestWithColumnName(t *testing.T) {
 			}
 			fd := m.FieldMap[tc.field]
 			assert.Equal(t, tc.wantColName, fd.ColName)
+			// 处理结果集的时候使用新的列名
+			assert.Equal(t, fd, m.ColumnMap[tc.wantColName])
+			assert.Len(t, m.ColumnMap, len(m.Fields))
+		})
+	}
+}
+
+func TestWithReadOnly(t *testing.T) {
+	r := NewRegistry()
+	m, err := r.Register(&TestModel{}, WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, m.ReadOnly)
+	// 后续 Get 拿到的是同一个只读模型
+	m, err = r.Get(&TestModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, m.ReadOnly)
+}
+
+func TestSoftDelete(t *testing.T) {
+	testCases := []struct {
+		name string
+		val  any
+		opts []Option
+		// wantField 是软删除字段，为空说明没有开启软删除
+		wantField string
+		wantErr   error
+	}{
+		{
+			name: "tag",
+			val: func() any {
+				type SoftDeleteTag struct {
+					Id        int64
+					DeletedAt *time.Time `orm:"column=dtime;soft_delete"`
+				}
+				return &SoftDeleteTag{}
+			}(),
+			wantField: "DeletedAt",
+		},
+		{
+			name: "option",
+			val: func() any {
+				type SoftDeleteOption struct {
+					Id        int64
+					DeletedAt sql.NullTime
+				}
+				return &SoftDeleteOption{}
+			}(),
+			opts:      []Option{WithSoftDelete("DeletedAt")},
+			wantField: "DeletedAt",
+		},
+		{
+			name: "none",
+			val:  &TestModel{},
+		},
+		{
+			// 不能为 NULL 的类型
+			name: "invalid type",
+			val: func() any {
+				type SoftDeleteInvalidType struct {
+					DeletedAt time.Time `orm:"soft_delete"`
+				}
+				return &SoftDeleteInvalidType{}
+			}(),
+			wantErr: errs.NewErrInvalidSoftDeleteField("DeletedAt", reflect.TypeOf(time.Time{})),
+		},
+		{
+			name: "multiple",
+			val: func() any {
+				type SoftDeleteMultiple struct {
+					DeletedAt *time.Time `orm:"soft_delete"`
+					RemovedAt *time.Time `orm:"soft_delete"`
+				}
+				return &SoftDeleteMultiple{}
+			}(),
+			wantErr: errs.ErrMultipleSoftDeleteFields,
+		},
+		{
+			name:    "option unknown field",
+			val:     &TestModel{},
+			opts:    []Option{WithSoftDelete("DeletedAt")},
+			wantErr: errs.NewErrUnknownField("DeletedAt"),
+		},
+		{
+			name:    "option invalid type",
+			val:     &TestModel{},
+			opts:    []Option{WithSoftDelete("Age")},
+			wantErr: errs.NewErrInvalidSoftDeleteField("Age", reflect.TypeOf(int8(0))),
+		},
+	}
+
+	r := NewRegistry()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := r.Register(tc.val, tc.opts...)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			if tc.wantField == "" {
+				assert.Nil(t, m.SoftDelete)
+				return
+			}
+			assert.Same(t, m.FieldMap[tc.wantField], m.SoftDelete)
 		})
 	}
 }
 
+// 使用 go test -race 运行可以检测数据竞争
+func TestRegistry_GetConcurrently(t *testing.T) {
+	r := NewRegistry()
+	const n = 100
+	models := make([]*Model, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			m, err := r.Get(&TestModel{})
+			assert.NoError(t, err)
+			models[i] = m
+		}(i)
+	}
+	wg.Wait()
+	// 所有的 goroutine 拿到的都是同一个 Model
+	for _, m := range models {
+		assert.Same(t, models[0], m)
+	}
+}
+
+func TestRegistryWithConverter(t *testing.T) {
+	type CustomConverter struct {
+		UnixTimeConverter
+	}
+	type ConverterModel struct {
+		CreatedAt time.Time `orm:"type=custom"`
+	}
+	r := NewRegistry(RegistryWithConverter("custom", CustomConverter{}))
+	m, err := r.Get(&ConverterModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, CustomConverter{}, m.FieldMap["CreatedAt"].Converter)
+}
+
 func TestRegistry_get(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -162,6 +322,32 @@ func TestRegistry_get(t *testing.T) {
 						Offset:  32,
 					},
 				},
+				Fields: []*Field{
+					{
+						ColName: "id",
+						Type:    reflect.TypeOf(int64(0)),
+						GoName:  "Id",
+						Offset:  0,
+					},
+					{
+						ColName: "first_name",
+						Type:    reflect.TypeOf(""),
+						GoName:  "FirstName",
+						Offset:  8,
+					},
+					{
+						ColName: "age",
+						Type:    reflect.TypeOf(int8(0)),
+						GoName:  "Age",
+						Offset:  24,
+					},
+					{
+						ColName: "last_name",
+						Type:    reflect.TypeOf(&sql.NullString{}),
+						GoName:  "LastName",
+						Offset:  32,
+					},
+				},
 			},
 		},
 		{
@@ -216,6 +402,13 @@ func TestRegistry_get(t *testing.T) {
 						GoName:  "ID",
 					},
 				},
+				Fields: []*Field{
+					{
+						ColName: "id",
+						Type:    reflect.TypeOf(uint64(0)),
+						GoName:  "ID",
+					},
+				},
 			},
 		},
 		{
@@ -244,6 +437,13 @@ func TestRegistry_get(t *testing.T) {
 						GoName:  "FirstName",
 					},
 				},
+				Fields: []*Field{
+					{
+						ColName: "first_name",
+						Type:    reflect.TypeOf(""),
+						GoName:  "FirstName",
+					},
+				},
 			},
 		},
 		{
@@ -284,9 +484,110 @@ func TestRegistry_get(t *testing.T) {
 						GoName:  "FirstName",
 					},
 				},
+				Fields: []*Field{
+					{
+						ColName: "first_name",
+						Type:    reflect.TypeOf(""),
+						GoName:  "FirstName",
+					},
+				},
 			},
 		},
 
+		{
+			// 通过 type 指定 Converter
+			name: "converter tag",
+			val: func() any {
+				type ConverterTag struct {
+					CreatedAt time.Time `orm:"column=ctime;type=unixtime"`
+				}
+				return &ConverterTag{}
+			}(),
+			wantModel: &Model{
+				TableName: "converter_tag",
+				FieldMap: map[string]*Field{
+					"CreatedAt": {
+						ColName:   "ctime",
+						Type:      reflect.TypeOf(time.Time{}),
+						GoName:    "CreatedAt",
+						Converter: UnixTimeConverter{},
+					},
+				},
+				ColumnMap: map[string]*Field{
+					"ctime": {
+						ColName:   "ctime",
+						Type:      reflect.TypeOf(time.Time{}),
+						GoName:    "CreatedAt",
+						Converter: UnixTimeConverter{},
+					},
+				},
+				Fields: []*Field{
+					{
+						ColName:   "ctime",
+						Type:      reflect.TypeOf(time.Time{}),
+						GoName:    "CreatedAt",
+						Converter: UnixTimeConverter{},
+					},
+				},
+			},
+		},
+		{
+			name: "unknown converter",
+			val: func() any {
+				type UnknownConverter struct {
+					CreatedAt time.Time `orm:"type=abc"`
+				}
+				return &UnknownConverter{}
+			}(),
+			wantErr: errs.NewErrUnknownConverter("abc"),
+		},
+		{
+			// unixtime 只能用于 time.Time
+			name: "converter on mismatched field",
+			val: func() any {
+				type MismatchedConverter struct {
+					CreatedAt int64 `orm:"type=unixtime"`
+				}
+				return &MismatchedConverter{}
+			}(),
+			wantErr: errs.NewErrConverterFieldType("unixtime", "CreatedAt", reflect.TypeOf(int64(0))),
+		},
+		{
+			// 指针也不行
+			name: "converter on pointer field",
+			val: func() any {
+				type PtrConverter struct {
+					CreatedAt *time.Time `orm:"type=unixtime"`
+				}
+				return &PtrConverter{}
+			}(),
+			wantErr: errs.NewErrConverterFieldType("unixtime", "CreatedAt", reflect.TypeOf(&time.Time{})),
+		},
+		{
+			// 两个字段映射到同一个列
+			name: "duplicate column",
+			val: func() any {
+				type DuplicateColumn struct {
+					FirstName string `orm:"column=name"`
+					LastName  string `orm:"column=name"`
+				}
+				return &DuplicateColumn{}
+			}(),
+			wantErr: errs.NewErrDuplicateColumn("name"),
+		},
+		{
+			// 标签和默认的列名冲突
+			name: "duplicate default column",
+			val: func() any {
+				type DuplicateDefaultColumn struct {
+					FirstName string
+					Name      string `orm:"column=first_name"`
+				}
+				return &DuplicateDefaultColumn{}
+			}(),
+			wantErr: errs.NewErrDuplicateColumn("first_name"),
+		},
+
 		// 利用接口自定义模型信息
 		{
 			name: "table name",
@@ -307,6 +608,13 @@ func TestRegistry_get(t *testing.T) {
 						Type:    reflect.TypeOf(""),
 					},
 				},
+				Fields: []*Field{
+					{
+						ColName: "name",
+						GoName:  "Name",
+						Type:    reflect.TypeOf(""),
+					},
+				},
 			},
 		},
 		{
@@ -328,6 +636,13 @@ func TestRegistry_get(t *testing.T) {
 						Type:    reflect.TypeOf(""),
 					},
 				},
+				Fields: []*Field{
+					{
+						ColName: "name",
+						GoName:  "Name",
+						Type:    reflect.TypeOf(""),
+					},
+				},
 			},
 		},
 		{
@@ -349,6 +664,13 @@ func TestRegistry_get(t *testing.T) {
 						Type:    reflect.TypeOf(""),
 					},
 				},
+				Fields: []*Field{
+					{
+						ColName: "name",
+						GoName:  "Name",
+						Type:    reflect.TypeOf(""),
+					},
+				},
 			},
 		},
 	}
@@ -376,18 +698,81 @@ func Test_underscoreName(t *testing.T) {
 		// 在忘记 underscoreName 的行为特性之后
 		// 可以从这里找回来
 		// 比如说过了一段时间之后
-		// 忘记了 ID 不能转化为 id
-		// 那么这个测试能帮我们确定 ID 只能转化为 i_d
+		// 忘记了连续的大写字母会被当成一个缩写
+		// 那么这个测试能帮我们确定 ID 会转化为 id 而不是 i_d
 		{
 			name:    "upper cases",
 			srcStr:  "ID",
-			wantStr: "i_d",
+			wantStr: "id",
+		},
+		{
+			name:    "acronym suffix",
+			srcStr:  "UserID",
+			wantStr: "user_id",
+		},
+		{
+			name:    "acronym prefix",
+			srcStr:  "HTTPCode",
+			wantStr: "http_code",
+		},
+		{
+			name:    "acronym middle",
+			srcStr:  "GetURLPath",
+			wantStr: "get_url_path",
+		},
+		{
+			// 缩写后面的 s 是复数
+			name:    "plural acronym suffix",
+			srcStr:  "UserIDs",
+			wantStr: "user_ids",
+		},
+		{
+			name:    "plural acronym",
+			srcStr:  "URLs",
+			wantStr: "urls",
+		},
+		{
+			name:    "plural acronym middle",
+			srcStr:  "GetURLsByID",
+			wantStr: "get_urls_by_id",
+		},
+		{
+			// 后面还有小写字母，说明 S 是下一个单词的开头
+			name:    "acronym before word starting with s",
+			srcStr:  "HTTPServer",
+			wantStr: "http_server",
 		},
 		{
 			name:    "use number",
 			srcStr:  "Table1Name",
 			wantStr: "table1_name",
 		},
+		{
+			// 数字跟着前面的字母，不会单独分出来
+			name:    "number after upper",
+			srcStr:  "V2Model",
+			wantStr: "v2_model",
+		},
+		{
+			name:    "acronym and number",
+			srcStr:  "OAuth2Token",
+			wantStr: "o_auth2_token",
+		},
+		{
+			name:    "lower cases",
+			srcStr:  "user",
+			wantStr: "user",
+		},
+		{
+			name:    "already underscore",
+			srcStr:  "User_Name",
+			wantStr: "user_name",
+		},
+		{
+			name:    "empty",
+			srcStr:  "",
+			wantStr: "",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -428,3 +813,31 @@ type TestModel struct {
 	Age       int8
 	LastName  *sql.NullString
 }
+
+// go test -bench=BenchmarkRegistry_Get -benchmem
+// parse 每次都重新解析，cached 则命中缓存，
+// cached 没有任何内存分配
+func BenchmarkRegistry_Get(b *testing.B) {
+	b.Run("parse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := NewRegistry().Get(&TestModel{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		r := NewRegistry()
+		tm := &TestModel{}
+		if _, err := r.Get(tm); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := r.Get(tm); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -136,6 +136,13 @@ CREATE TABLE IF NOT EXISTS test_model(
 `
 }
 
+// SoftDeleteModel 开启了软删除的模型
+type SoftDeleteModel struct {
+	Id        int64
+	Name      string
+	DeletedAt *time.Time `orm:"soft_delete"`
+}
+
 // memoryDB 返回一个基于内存的 ORM，它使用的是 sqlite3 内存模式。
 func memoryDB(t *testing.T) *DB {
 	orm, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory")
@@ -5,28 +5,93 @@ type op string
 
 // 后面可以每次支持新的操作符就加一个
 const (
-	opEQ  = "="
-	opLT  = "<"
-	opGT  = ">"
-	opAND = "AND"
-	opOR  = "OR"
-	opNOT = "NOT"
+	opEQ    = "="
+	opNEQ   = "!="
+	opLT    = "<"
+	opLTEQ  = "<="
+	opGT    = ">"
+	opGTEQ  = ">="
+	opAND   = "AND"
+	opOR    = "OR"
+	opNOT   = "NOT"
+	opAdd   = "+"
+	opSub   = "-"
+	opMulti = "*"
+
+	opIn    = "IN"
+	opNotIn = "NOT IN"
+
+	opLike    = "LIKE"
+	opNotLike = "NOT LIKE"
+
+	opBetween   = "BETWEEN"
+	opIsNull    = "IS NULL"
+	opIsNotNull = "IS NOT NULL"
 )
 
 func (o op) String() string {
 	return string(o)
 }
 
+// precedence 返回操作符的优先级，数字越大优先级越高。
+// 没有列出来的都是比较操作符，例如 =, LIKE, IN 和 IS NULL
+func (o op) precedence() int {
+	switch o {
+	case opOR:
+		return 1
+	case opAND:
+		return 2
+	case opNOT:
+		return 3
+	case opAdd, opSub:
+		return 5
+	case opMulti:
+		return 6
+	default:
+		return 4
+	}
+}
+
+// associative 是否满足结合律，满足的话 a AND (b AND c) 可以去掉括号
+func (o op) associative() bool {
+	switch o {
+	case opAND, opOR, opAdd, opMulti:
+		return true
+	default:
+		return false
+	}
+}
+
+// precedenceOf 返回表达式的优先级，只有 Predicate 和 MathExpr 有优先级。
+// 原生表达式的内容是未知的，所以它的优先级最低，作为操作数的时候总是加上括号
+func precedenceOf(e Expression) (int, bool) {
+	switch exp := e.(type) {
+	case Predicate:
+		if exp.op == "" {
+			return 0, true
+		}
+		return exp.op.precedence(), true
+	case MathExpr:
+		return exp.op.precedence(), true
+	default:
+		return 0, false
+	}
+}
+
 // Expression 代表语句，或者语句的部分
 // 暂时没想好怎么设计方法，所以直接做成标记接口
 type Expression interface {
 	expr()
 }
 
+// exprOf 将 e 转化为表达式。列、聚合函数这种表达式直接拼接到 SQL 里面，
+// *Selector 这种 QueryBuilder 作为子查询，其它的都被看做是参数
 func exprOf(e any) Expression {
 	switch exp := e.(type) {
 	case Expression:
 		return exp
+	case QueryBuilder:
+		return Subquery{s: exp}
 	default:
 		return valueOf(exp)
 	}
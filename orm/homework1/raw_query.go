@@ -0,0 +1,134 @@
+package orm
+
+import (
+	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+)
+
+var _ Querier[any] = &RawQuerier[any]{}
+
+// RawQuerier 原生查询器
+// T 不需要对应任何一张表，只需要字段（或者 column 标签）能够和结果集的列对上。
+// 所以它也可以用于 JOIN 查询，例如：
+//
+//	type UserOrder struct {
+//		UserId  int64  `orm:"column=user_id"`
+//		OrderId int64  `orm:"column=order_id"`
+//	}
+type RawQuerier[T any] struct {
+	db   *DB
+	sess Session
+	sql  string
+	args []any
+}
+
+// RawQuery 创建一个 RawQuerier 实例
+// 泛型参数 T 是目标类型。
+// 例如，如果查询 User 的数据，那么 T 就是 User
+func RawQuery[T any](sess Session, sql string, args ...any) *RawQuerier[T] {
+	return &RawQuerier[T]{
+		db:   sess.getDB(),
+		sess: sess,
+		sql:  sql,
+		args: args,
+	}
+}
+
+func (r *RawQuerier[T]) Build() (*Query, error) {
+	return &Query{
+		SQL:  r.sql,
+		Args: r.args,
+	}, nil
+}
+
+func (r *RawQuerier[T]) Get(ctx context.Context) (*T, error) {
+	var t T
+	meta, err := r.db.r.Get(&t)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel, err := r.db.prepareContext(ctx, "RawQuerier")
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	res := r.db.handle(ctx, r.queryContext(meta), func(ctx context.Context, qc *QueryContext) *QueryResult {
+		tp, err := r.get(ctx, qc.Query, meta)
+		return &QueryResult{Result: tp, Err: err}
+	})
+	tp, _ := res.Result.(*T)
+	return tp, res.Err
+}
+
+func (r *RawQuerier[T]) queryContext(meta *model.Model) *QueryContext {
+	return &QueryContext{
+		Type: "RAW",
+		Query: &Query{
+			SQL:  r.sql,
+			Args: r.args,
+		},
+		Model: meta,
+	}
+}
+
+func (r *RawQuerier[T]) get(ctx context.Context, q *Query, meta *model.Model) (*T, error) {
+	rows, err := r.sess.queryContext(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNoRows
+	}
+	tp := new(T)
+	val := r.db.valCreator(tp, meta)
+	if err = val.SetColumns(rows); err != nil {
+		return nil, err
+	}
+	return tp, nil
+}
+
+func (r *RawQuerier[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	var t T
+	meta, err := r.db.r.Get(&t)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel, err := r.db.prepareContext(ctx, "RawQuerier")
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	res := r.db.handle(ctx, r.queryContext(meta), func(ctx context.Context, qc *QueryContext) *QueryResult {
+		tps, err := r.getMulti(ctx, qc.Query, meta)
+		return &QueryResult{Result: tps, Err: err}
+	})
+	tps, _ := res.Result.([]*T)
+	return tps, res.Err
+}
+
+func (r *RawQuerier[T]) getMulti(ctx context.Context, q *Query, meta *model.Model) ([]*T, error) {
+	rows, err := r.sess.queryContext(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]*T, 0, 8)
+	for rows.Next() {
+		tp := new(T)
+		val := r.db.valCreator(tp, meta)
+		if err = val.SetColumns(rows); err != nil {
+			return nil, err
+		}
+		res = append(res, tp)
+	}
+	return res, rows.Err()
+}
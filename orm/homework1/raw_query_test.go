@@ -0,0 +1,111 @@
+package orm
+
+import (
+	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+// UserOrder 是 JOIN 查询的结果，不对应任何一张表
+type UserOrder struct {
+	UserId    int64  `orm:"column=user_id"`
+	UserName  string `orm:"column=user_name"`
+	OrderId   int64  `orm:"column=order_id"`
+	OrderDesc string `orm:"column=order_desc"`
+}
+
+func TestRawQuerier_GetMulti(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	joinSQL := "SELECT u.`id` AS `user_id`,u.`name` AS `user_name`,o.`id` AS `order_id`,o.`desc` AS `order_desc` " +
+		"FROM `user` AS u JOIN `order` AS o ON u.`id` = o.`user_id` WHERE u.`id` = ?;"
+	mock.ExpectQuery(regexp.QuoteMeta(joinSQL)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "user_name", "order_id", "order_desc"}).
+			AddRow([]byte("1"), []byte("Tom"), []byte("11"), []byte("book")).
+			AddRow([]byte("1"), []byte("Tom"), []byte("12"), []byte("pen")))
+	// 列的顺序和字段的顺序不一致也可以
+	mock.ExpectQuery(regexp.QuoteMeta(joinSQL)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id", "user_id"}).
+			AddRow([]byte("21"), []byte("2")))
+	mock.ExpectQuery(regexp.QuoteMeta(joinSQL)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "amount"}).
+			AddRow([]byte("3"), []byte("100")))
+
+	testCases := []struct {
+		name    string
+		arg     int
+		wantRes []*UserOrder
+		wantErr error
+	}{
+		{
+			name: "join",
+			arg:  1,
+			wantRes: []*UserOrder{
+				{UserId: 1, UserName: "Tom", OrderId: 11, OrderDesc: "book"},
+				{UserId: 1, UserName: "Tom", OrderId: 12, OrderDesc: "pen"},
+			},
+		},
+		{
+			name: "partial columns",
+			arg:  2,
+			wantRes: []*UserOrder{
+				{UserId: 2, OrderId: 21},
+			},
+		},
+		{
+			name:    "unknown column",
+			arg:     3,
+			wantErr: errs.NewErrUnknownColumn("amount"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := RawQuery[UserOrder](db, joinSQL, tc.arg).GetMulti(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRawQuerier_Get(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "order_id"}).
+			AddRow([]byte("1"), []byte("11")))
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "order_id"}))
+
+	res, err := RawQuery[UserOrder](db, "SELECT u.`id` AS `user_id`,o.`id` AS `order_id` FROM `user` AS u JOIN `order` AS o ON u.`id` = o.`user_id`;").
+		Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, &UserOrder{UserId: 1, OrderId: 11}, res)
+
+	_, err = RawQuery[UserOrder](db, "SELECT u.`id` AS `user_id`,o.`id` AS `order_id` FROM `user` AS u JOIN `order` AS o ON u.`id` = o.`user_id`;").
+		Get(context.Background())
+	assert.Equal(t, ErrNoRows, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
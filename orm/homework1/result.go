@@ -0,0 +1,44 @@
+package orm
+
+import (
+	"database/sql"
+)
+
+var _ sql.Result = Result{}
+
+// Result 是执行 INSERT, UPDATE 和 DELETE 语句的结果。
+// 它同时记录了构造语句和执行语句的错误，所以可以直接链式调用，例如
+// NewUpdater[User](db).Set(Assign("Age", 18)).Exec(ctx).RowsAffected()
+// 这种写法需要忽略 Exec 返回的 error，因为 RowsAffected 会返回同一个 error
+type Result struct {
+	res sql.Result
+	err error
+}
+
+// Err 返回构造或者执行语句的过程中出现的错误
+func (r Result) Err() error {
+	return r.err
+}
+
+// RowsAffected 返回受影响的行数，如果构造或者执行语句失败，那么返回对应的错误
+func (r Result) RowsAffected() (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	// 中间件可能直接返回结果而没有真的执行语句
+	if r.res == nil {
+		return 0, nil
+	}
+	return r.res.RowsAffected()
+}
+
+// LastInsertId 返回最后插入的 ID，如果构造或者执行语句失败，那么返回对应的错误
+func (r Result) LastInsertId() (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.res == nil {
+		return 0, nil
+	}
+	return r.res.LastInsertId()
+}
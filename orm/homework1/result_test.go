@@ -0,0 +1,103 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestResult(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name         string
+		mock         func()
+		exec         func() (Result, error)
+		wantAffected int64
+		wantId       int64
+		wantErr      error
+	}{
+		{
+			name: "update rows affected",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_model` SET `age`=? WHERE `id` = ?;")).
+					WithArgs(18, 1).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			exec: func() (Result, error) {
+				return NewUpdater[TestModel](db).Set(Assign("Age", 18)).
+					Where(C("Id").EQ(1)).Exec(ctx)
+			},
+			wantAffected: 3,
+		},
+		{
+			name: "insert last insert id",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `test_model`(`first_name`) VALUES (?);")).
+					WithArgs("Tom").
+					WillReturnResult(sqlmock.NewResult(12, 1))
+			},
+			exec: func() (Result, error) {
+				return NewInserter[TestModel](db).Values(&TestModel{FirstName: "Tom"}).
+					Columns("FirstName").Exec(ctx)
+			},
+			wantAffected: 1,
+			wantId:       12,
+		},
+		{
+			name: "delete",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `test_model`;")).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			exec: func() (Result, error) {
+				return NewDeleter[TestModel](db).Exec(ctx)
+			},
+			wantAffected: 2,
+		},
+		{
+			// 构造语句失败，不会执行
+			name: "build error",
+			mock: func() {},
+			exec: func() (Result, error) {
+				return NewUpdater[TestModel](db).Exec(ctx)
+			},
+			wantErr: errs.ErrNoUpdatedColumns,
+		},
+		{
+			name: "exec error",
+			mock: func() {
+				mock.ExpectExec("DELETE .*").WillReturnError(errors.New("exec error"))
+			},
+			exec: func() (Result, error) {
+				return NewDeleter[TestModel](db).Exec(ctx)
+			},
+			wantErr: errors.New("exec error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mock()
+			// 直接使用 Result，而不检查 Exec 返回的 error
+			res, _ := tc.exec()
+			assert.Equal(t, tc.wantErr, res.Err())
+			affected, err := res.RowsAffected()
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantAffected, affected)
+			id, err := res.LastInsertId()
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantId, id)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
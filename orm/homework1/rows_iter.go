@@ -0,0 +1,85 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+)
+
+// RowsIter 是查询结果集的迭代器，直接基于 *sql.Rows，
+// 不需要像 GetMulti 那样把所有数据都读到切片里面，调用者可以随时中止。
+// 用完之后一定要调用 Close
+type RowsIter[T any] struct {
+	rows  *sql.Rows
+	db    *DB
+	model *model.Model
+	// cancel 释放 DBWithQueryTimeout 加上的超时，迭代结束之前不能调用
+	cancel context.CancelFunc
+}
+
+// Next 准备下一行数据，没有数据或者出错的时候返回 false，
+// 出错的时候需要通过 Err 来获得错误
+func (r *RowsIter[T]) Next() bool {
+	return r.rows.Next()
+}
+
+// Scan 将当前行数据转化为 T，必须在 Next 返回 true 之后调用
+func (r *RowsIter[T]) Scan() (*T, error) {
+	tp := new(T)
+	val := r.db.valCreator(tp, r.model)
+	if err := val.SetColumns(r.rows); err != nil {
+		return nil, err
+	}
+	return tp, nil
+}
+
+// Err 返回迭代过程中遇到的错误
+func (r *RowsIter[T]) Err() error {
+	return r.rows.Err()
+}
+
+// Close 关闭结果集，可以重复调用
+func (r *RowsIter[T]) Close() error {
+	err := r.rows.Close()
+	r.cancel()
+	return err
+}
+
+// Iter 执行查询并返回结果集的迭代器
+func (s *Selector[T]) Iter(ctx context.Context) (*RowsIter[T], error) {
+	q, err := s.Build()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel, err := s.db.prepareContext(ctx, "Selector")
+	if err != nil {
+		return nil, err
+	}
+	qc := &QueryContext{
+		Type:  "SELECT",
+		Query: q,
+		Model: s.model,
+	}
+	res := s.db.handle(ctx, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		rows, err := s.sess.queryContext(ctx, qc.Query.SQL, qc.Query.Args...)
+		if err != nil {
+			return &QueryResult{Err: s.queryErr("SELECT", qc.Query, err)}
+		}
+		return &QueryResult{Result: rows}
+	})
+	rows, _ := res.Result.(*sql.Rows)
+	if res.Err != nil || rows == nil {
+		// 中间件可能吞掉了结果集，这个时候也要关闭它
+		if rows != nil {
+			_ = rows.Close()
+		}
+		cancel()
+		return nil, res.Err
+	}
+	return &RowsIter[T]{
+		rows:   rows,
+		db:     s.db,
+		model:  s.model,
+		cancel: cancel,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSelector_Iter(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"}).
+			AddRow([]byte("1"), []byte("Tom"), []byte("18"), []byte("Jerry")).
+			AddRow([]byte("2"), []byte("Tom"), []byte("20"), []byte("Jerry")).
+			AddRow([]byte("3"), []byte("Tom"), []byte("22"), []byte("Jerry"))).
+		RowsWillBeClosed()
+
+	iter, err := NewSelector[TestModel](db).Iter(context.Background())
+	require.NoError(t, err)
+	var res []*TestModel
+	// 只读取前两行就中止
+	for len(res) < 2 && iter.Next() {
+		tm, err := iter.Scan()
+		require.NoError(t, err)
+		res = append(res, tm)
+	}
+	require.NoError(t, iter.Close())
+	assert.NoError(t, iter.Err())
+	// 关闭之后不能再读取数据
+	assert.False(t, iter.Next())
+	assert.Equal(t, []*TestModel{
+		{
+			Id:        1,
+			FirstName: "Tom",
+			Age:       18,
+			LastName:  &sql.NullString{String: "Jerry", Valid: true},
+		},
+		{
+			Id:        2,
+			FirstName: "Tom",
+			Age:       20,
+			LastName:  &sql.NullString{String: "Jerry", Valid: true},
+		},
+	}, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
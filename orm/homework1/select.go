@@ -2,37 +2,101 @@ package orm
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
-	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+	"strconv"
 	"strings"
-	"unicode"
 )
 
 // Selector 用于构造 SELECT 语句
 type Selector[T any] struct {
-	sb      strings.Builder
-	args    []any
-	table   string
+	builder
+	table   TableReference
 	where   []Predicate
 	having  []Predicate
-	model   *model.Model
-	db      *DB
 	columns []Selectable
 	groupBy []Column
 	orderBy []OrderBy
 	offset  int
 	limit   int
+	// offsetSet 和 limitSet 标记是否调用过 Offset 和 Limit，
+	// 这样才能区分 Limit(0) 和没有设置 LIMIT
+	offsetSet bool
+	limitSet  bool
+	setOps    []setOperation
+	distinct  bool
+	// lock 是锁定读的模式，例如 FOR UPDATE
+	lock string
+	// unscoped 为 true 的时候不过滤软删除的数据
+	unscoped bool
+	// excepts 是 SelectExcept 排除的字段，不为 nil 的时候在 Build 里面展开成 columns
+	excepts []string
+}
+
+const (
+	setOpUnion     = "UNION"
+	setOpUnionAll  = "UNION ALL"
+	setOpIntersect = "INTERSECT"
+	setOpExcept    = "EXCEPT"
+)
+
+const (
+	lockForUpdate = "FOR UPDATE"
+	lockForShare  = "FOR SHARE"
+)
+
+// setOperation 代表 UNION, INTERSECT 和 EXCEPT 这一类集合操作
+type setOperation struct {
+	op string
+	q  QueryBuilder
 }
 
 func (s *Selector[T]) Select(cols ...Selectable) *Selector[T] {
 	s.columns = cols
+	s.excepts = nil
 	return s
 }
 
-// From 指定表名，如果是空字符串，那么将会使用默认表名
-func (s *Selector[T]) From(tbl string) *Selector[T] {
+// SelectExcept 查询模型除了 fds 以外的全部字段，按照模型字段的顺序排列，
+// 例如 SelectExcept("Password") 用于查询一整行但是不查询敏感的列。
+// fds 是字段名，遇到未知字段会返回错误。它会覆盖 Select 指定的列
+func (s *Selector[T]) SelectExcept(fds ...string) *Selector[T] {
+	s.columns = nil
+	s.excepts = append(make([]string, 0, len(fds)), fds...)
+	return s
+}
+
+// Distinct 生成 SELECT DISTINCT，对选择的列去重
+func (s *Selector[T]) Distinct() *Selector[T] {
+	s.distinct = true
+	return s
+}
+
+// ForUpdate 生成 SELECT ... FOR UPDATE，锁住读到的行。
+// 只有在事务里面执行才有意义
+func (s *Selector[T]) ForUpdate() *Selector[T] {
+	s.lock = lockForUpdate
+	return s
+}
+
+// ForShare 生成共享锁的锁定读，MySQL 是 LOCK IN SHARE MODE，PostgreSQL 是 FOR SHARE。
+// 只有在事务里面执行才有意义
+func (s *Selector[T]) ForShare() *Selector[T] {
+	s.lock = lockForShare
+	return s
+}
+
+// Unscoped 不再过滤软删除的数据，用于查询已经被删除的数据
+func (s *Selector[T]) Unscoped() *Selector[T] {
+	s.unscoped = true
+	return s
+}
+
+// From 指定 FROM 部分，可以是 TableOf 得到的表，Join，或者是 AsSubquery 得到的子查询。
+// 使用子查询的时候，没有指定表的列都在子查询里面查找，而不是在 T 里面查找。
+// 如果需要直接写表名，可以使用 Raw，例如 From(Raw("`user`"))。
+// 如果没有调用或者传入 nil，那么将会使用默认表名
+func (s *Selector[T]) From(tbl TableReference) *Selector[T] {
 	s.table = tbl
 	return s
 }
@@ -42,29 +106,48 @@ func (s *Selector[T]) Build() (*Query, error) {
 		t   T
 		err error
 	)
-	s.model, err = s.db.r.Get(&t)
-	if err != nil {
+	if err = s.resolveModel(&t); err != nil {
 		return nil, err
 	}
+	s.from = s.table
+	if s.excepts != nil {
+		if s.columns, err = s.exceptColumns(); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.validate(); err != nil {
+		return nil, err
+	}
+	if s.db.strictGroupBy {
+		if err = s.validateGroupBy(); err != nil {
+			return nil, err
+		}
+	}
+	// 重置状态，这样 Build 可以被重复调用，例如先 Debug 再 Get
+	s.sb.Reset()
+	s.args = nil
 	s.sb.WriteString("SELECT ")
+	if s.distinct {
+		s.sb.WriteString("DISTINCT ")
+	}
 	if err = s.buildColumns(); err != nil {
 		return nil, err
 	}
 	s.sb.WriteString(" FROM ")
-	if s.table == "" {
-		s.sb.WriteByte('`')
-		s.sb.WriteString(s.model.TableName)
-		s.sb.WriteByte('`')
-	} else {
-		s.sb.WriteString(s.table)
+	if err = s.buildTable(s.table); err != nil {
+		return nil, err
 	}
 
 	// 构造 WHERE
-	if len(s.where) > 0 {
+	where, err := s.scopedWhere()
+	if err != nil {
+		return nil, err
+	}
+	if len(where) > 0 {
 		// 类似这种可有可无的部分，都要在前面加一个空格
 		s.sb.WriteString(" WHERE ")
 		// WHERE 是不允许用别名的
-		if err = s.buildPredicates(s.where); err != nil {
+		if err = s.buildPredicates(where); err != nil {
 			return nil, err
 		}
 	}
@@ -85,6 +168,12 @@ func (s *Selector[T]) Build() (*Query, error) {
 		}
 	}
 
+	if len(s.setOps) > 0 {
+		if err = s.buildSetOps(); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(s.orderBy) > 0 {
 		s.sb.WriteString(" ORDER BY ")
 		err := s.buildOrderBy()
@@ -92,11 +181,30 @@ func (s *Selector[T]) Build() (*Query, error) {
 			return nil, err
 		}
 	}
-	if s.limit != 0 {
-		s.buildLimit(s.limit)
+	if s.limitSet {
+		if err = s.buildLimit(s.limit); err != nil {
+			return nil, err
+		}
+	} else if s.offsetSet {
+		// 部分数据库不允许单独使用 OFFSET
+		if noLimit := s.db.dialect.noLimit(); noLimit != "" {
+			s.sb.WriteString(" LIMIT ")
+			s.sb.WriteString(noLimit)
+		}
 	}
-	if s.offset != 0 {
-		s.buildOffset(s.offset)
+	if s.offsetSet {
+		if err = s.buildOffset(s.offset); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.lock != "" {
+		clause := s.db.dialect.lockClause(s.lock)
+		if clause == "" {
+			return nil, errs.NewErrUnsupportedLock(s.lock)
+		}
+		s.sb.WriteByte(' ')
+		s.sb.WriteString(clause)
 	}
 
 	s.sb.WriteString(";")
@@ -106,12 +214,281 @@ func (s *Selector[T]) Build() (*Query, error) {
 	}, nil
 }
 
+// scopedWhere 在 WHERE 后面加上过滤软删除数据的条件。
+// 只有 FROM 是单个表的时候才会过滤，JOIN 和子查询需要用户自己处理
+func (s *Selector[T]) scopedWhere() ([]Predicate, error) {
+	if s.unscoped {
+		return s.where, nil
+	}
+	var p Predicate
+	switch tab := s.table.(type) {
+	case nil, RawExpr:
+		if s.model.SoftDelete == nil {
+			return s.where, nil
+		}
+		p = C(s.model.SoftDelete.GoName).IsNull()
+	case Table:
+		m, err := s.db.r.Get(tab.entity)
+		if err != nil {
+			return nil, err
+		}
+		if m.SoftDelete == nil {
+			return s.where, nil
+		}
+		p = tab.C(m.SoftDelete.GoName).IsNull()
+	default:
+		return s.where, nil
+	}
+	where := make([]Predicate, 0, len(s.where)+1)
+	return append(append(where, s.where...), p), nil
+}
+
+// ToSQL 返回 Build 构造的 SQL 和参数，不会执行
+func (s *Selector[T]) ToSQL() (string, []any, error) {
+	return toSQL(s.Build())
+}
+
+// Debug 返回构造好的 SQL，方便在测试或者调试的时候直接打印。
+// 和 Build 不同，它不会返回 error，构造失败的时候返回的是错误信息，
+// 所以不要用它来执行查询
+func (s *Selector[T]) Debug() (res string) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = fmt.Sprintf("orm: 构造 SQL 失败 %v", r)
+		}
+	}()
+	q, err := s.Build()
+	if err != nil {
+		return err.Error()
+	}
+	return q.SQL
+}
+
+// validate 在构造 SQL 之前校验所有用到的字段都属于模型，
+// 它会收集所有的未知字段，而不是遇到第一个就返回。
+// 传入了 nil 或者空的列名说明用法有问题，所以优先返回这两种错误
+func (s *Selector[T]) validate() error {
+	var (
+		unknown   []string
+		empty     bool
+		ambiguous error
+	)
+	seen := make(map[string]struct{}, 4)
+	check := func(fd string) {
+		if fd == "" {
+			empty = true
+			return
+		}
+		_, err := s.colName(nil, fd)
+		if err == nil {
+			return
+		}
+		// JOIN 的多个表都有这个字段，不能当作未知字段
+		if j, ok := s.from.(Join); ok && len(s.joinedTables(j, fd, nil)) > 1 {
+			if ambiguous == nil {
+				ambiguous = err
+			}
+			return
+		}
+		if _, ok := seen[fd]; ok {
+			return
+		}
+		seen[fd] = struct{}{}
+		unknown = append(unknown, fd)
+	}
+	for _, c := range s.columns {
+		switch val := c.(type) {
+		case nil:
+			return errs.ErrNilSelectable
+		case Column:
+			if val.table == nil || val.name == "" {
+				check(val.name)
+			}
+		case Aggregate:
+			check(val.arg)
+		case CondAggregate:
+			s.validateExpression(val.cond, check)
+		case MathExpr:
+			s.validateExpression(val, check)
+		}
+	}
+	for _, p := range s.where {
+		s.validateExpression(p, check)
+	}
+	for _, c := range s.groupBy {
+		if c.table == nil {
+			check(c.name)
+		}
+	}
+	for _, p := range s.having {
+		s.validateExpression(p, check)
+	}
+	for _, ob := range s.orderBy {
+		switch col := ob.col.(type) {
+		case Column:
+			if col.table == nil {
+				check(col.name)
+			}
+		case Aggregate:
+			check(col.arg)
+		}
+	}
+	if empty {
+		return errs.ErrEmptyColumn
+	}
+	if ambiguous != nil {
+		return ambiguous
+	}
+	switch len(unknown) {
+	case 0:
+		return nil
+	case 1:
+		return errs.NewErrUnknownField(unknown[0])
+	default:
+		return errs.NewErrUnknownFields(unknown)
+	}
+}
+
+// validateGroupBy 校验有 GROUP BY 的时候，SELECT 的列要么是分组的列，要么在聚合函数里面。
+// 列按照表的别名和字段名比较，忽略列的别名。没有指定列的时候是 *，不做校验
+func (s *Selector[T]) validateGroupBy() error {
+	if len(s.groupBy) == 0 {
+		return nil
+	}
+	grouped := make(map[string]struct{}, len(s.groupBy))
+	for _, c := range s.groupBy {
+		grouped[groupByKey(c)] = struct{}{}
+	}
+	var check func(e Expression) error
+	check = func(e Expression) error {
+		switch exp := e.(type) {
+		case Column:
+			if _, ok := grouped[groupByKey(exp)]; !ok {
+				return errs.NewErrUngroupedColumn(exp.name)
+			}
+		case MathExpr:
+			if err := check(exp.left); err != nil {
+				return err
+			}
+			return check(exp.right)
+		}
+		// 聚合函数，原生表达式和参数都不需要校验
+		return nil
+	}
+	for _, c := range s.columns {
+		if e, ok := c.(Expression); ok {
+			if err := check(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func groupByKey(c Column) string {
+	if c.table == nil {
+		return c.name
+	}
+	return c.table.tableAlias() + "." + c.name
+}
+
+// exceptColumns 将 SelectExcept 排除的字段展开成剩下的列
+func (s *Selector[T]) exceptColumns() ([]Selectable, error) {
+	var unknown []string
+	excepts := make(map[string]struct{}, len(s.excepts))
+	for _, fd := range s.excepts {
+		if _, ok := s.model.FieldMap[fd]; !ok {
+			unknown = append(unknown, fd)
+			continue
+		}
+		excepts[fd] = struct{}{}
+	}
+	switch len(unknown) {
+	case 0:
+	case 1:
+		return nil, errs.NewErrUnknownField(unknown[0])
+	default:
+		return nil, errs.NewErrUnknownFields(unknown)
+	}
+	cols := make([]Selectable, 0, len(s.model.Fields))
+	for _, fd := range s.model.Fields {
+		if _, ok := excepts[fd.GoName]; !ok {
+			cols = append(cols, C(fd.GoName))
+		}
+	}
+	// 全部排除之后什么都不剩，不能退化成 SELECT *
+	if len(cols) == 0 {
+		return nil, errs.ErrNoSelectedColumns
+	}
+	return cols, nil
+}
+
+func (s *Selector[T]) buildTable(table TableReference) error {
+	switch tab := table.(type) {
+	case nil:
+		return s.quote(s.model.TableName)
+	case Table:
+		m, err := s.db.r.Get(tab.entity)
+		if err != nil {
+			return err
+		}
+		if err = s.quote(m.TableName); err != nil {
+			return err
+		}
+		return s.buildAs(tab.alias)
+	case Join:
+		return s.buildJoin(tab)
+	case Subquery:
+		if err := s.buildSubquery(tab); err != nil {
+			return err
+		}
+		return s.buildAs(tab.alias)
+	case RawExpr:
+		s.buildRaw(tab)
+		return nil
+	default:
+		return errs.NewErrUnsupportedTableReference(table)
+	}
+}
+
+// buildJoin 构造 JOIN 部分，例如 `order` JOIN `user` ON `order`.`user_id` = `user`.`id`
+func (s *Selector[T]) buildJoin(j Join) error {
+	if err := s.buildTable(j.left); err != nil {
+		return err
+	}
+	s.sb.WriteByte(' ')
+	s.sb.WriteString(j.typ)
+	s.sb.WriteByte(' ')
+	if err := s.buildTable(j.right); err != nil {
+		return err
+	}
+	if len(j.on) > 0 {
+		s.sb.WriteString(" ON ")
+		return s.buildPredicates(j.on)
+	}
+	return nil
+}
+
 func (s *Selector[T]) buildOrderBy() error {
 	for idx, ob := range s.orderBy {
 		if idx > 0 {
 			s.sb.WriteByte(',')
 		}
-		err := s.buildColumn(ob.col, "")
+		if ob.random {
+			s.sb.WriteString(s.db.dialect.randomFunc())
+			continue
+		}
+		var err error
+		switch col := ob.col.(type) {
+		case Column:
+			err = s.buildTableColumn(col.table, col.name, "")
+		case Aggregate:
+			err = s.buildAggregate(col, false)
+		case RawExpr:
+			err = s.buildExpression(col)
+		default:
+			err = errs.NewErrUnsupportedSelectable(col)
+		}
 		if err != nil {
 			return err
 		}
@@ -126,31 +503,59 @@ func (s *Selector[T]) buildGroupBy() error {
 		if idx > 0 {
 			s.sb.WriteByte(',')
 		}
-		err := s.buildColumn(ob.name, "")
+		err := s.buildTableColumn(ob.table, ob.name, "")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildSetOps 构造集合操作。
+// 注意参与集合操作的查询不能带括号，因为 SQLite3 不支持
+func (s *Selector[T]) buildSetOps() error {
+	for _, so := range s.setOps {
+		if !s.db.dialect.supportSetOp(so.op) {
+			return errs.NewErrUnsupportedSetOperation(so.op)
+		}
+		q, err := s.buildSub(so.q)
 		if err != nil {
 			return err
 		}
+		s.sb.WriteByte(' ')
+		s.sb.WriteString(so.op)
+		s.sb.WriteByte(' ')
+		// 原生查询不一定以分号结尾
+		s.sb.WriteString(strings.TrimSuffix(q.SQL, ";"))
+		if len(q.Args) > 0 {
+			s.addArgs(q.Args...)
+		}
 	}
 	return nil
 }
 
-func (s *Selector[T]) buildOffset(offset int) {
-	s.sb.WriteString(" OFFSET ?")
-	s.addArgs(offset)
+func (s *Selector[T]) buildOffset(offset int) error {
+	s.sb.WriteString(" OFFSET ")
+	return s.buildLimitOffsetValue("OFFSET", offset)
 }
 
-func (s *Selector[T]) buildLimit(limit int) {
-	s.sb.WriteString(" LIMIT ?")
-	s.addArgs(s.limit)
+func (s *Selector[T]) buildLimit(limit int) error {
+	s.sb.WriteString(" LIMIT ")
+	return s.buildLimitOffsetValue("LIMIT", limit)
 }
 
-func (s *Selector[T]) buildPredicates(ps []Predicate) error {
-	p := ps[0]
-	for i := 1; i < len(ps); i++ {
-		p = p.And(ps[i])
+// buildLimitOffsetValue 默认使用占位符，
+// 开启了 DBWithInlineLimitOffset 之后直接将值写进 SQL
+func (s *Selector[T]) buildLimitOffsetValue(clause string, val int) error {
+	if !s.db.inlineLimitOffset {
+		s.buildArg(val)
+		return nil
 	}
-	isFirst := true
-	return s.buildExpression(p, isFirst)
+	if val < 0 {
+		return errs.NewErrInvalidLimitOffset(clause, val)
+	}
+	s.sb.WriteString(strconv.Itoa(val))
+	return nil
 }
 
 func (s *Selector[T]) buildColumns() error {
@@ -164,18 +569,26 @@ func (s *Selector[T]) buildColumns() error {
 		}
 		switch val := c.(type) {
 		case Column:
-			if err := s.buildColumn(val.name, val.alias); err != nil {
+			if err := s.buildTableColumn(val.table, val.name, val.alias); err != nil {
 				return err
 			}
 		case Aggregate:
 			if err := s.buildAggregate(val, true); err != nil {
 				return err
 			}
-		case RawExpr:
-			s.sb.WriteString(val.raw)
-			if len(val.args) != 0 {
-				s.addArgs(val.args...)
+		case CondAggregate:
+			if err := s.buildCondAggregate(val); err != nil {
+				return err
 			}
+		case MathExpr:
+			if err := s.buildExpression(val); err != nil {
+				return err
+			}
+			if err := s.buildAs(val.alias); err != nil {
+				return err
+			}
+		case RawExpr:
+			s.buildRaw(val)
 		default:
 			return errs.NewErrUnsupportedSelectable(c)
 		}
@@ -183,61 +596,24 @@ func (s *Selector[T]) buildColumns() error {
 	return nil
 }
 
-func (s *Selector[T]) buildAggregate(a Aggregate, useAlias bool) error {
-	s.sb.WriteString(a.fn)
-	s.sb.WriteString("(`")
-	fd, ok := s.model.FieldMap[a.arg]
-	if !ok {
-		return errs.NewErrUnknownField(a.arg)
-	}
-	s.sb.WriteString(fd.ColName)
-	s.sb.WriteString("`)")
-	if useAlias {
-		s.buildAs(a.alias)
-	}
-	return nil
-}
-
-func (s *Selector[T]) buildColumn(c string, alias string) error {
-	s.sb.WriteByte('`')
-	fd, ok := s.model.FieldMap[c]
-	if !ok {
-		return errs.NewErrUnknownField(c)
-	}
-	s.sb.WriteString(fd.ColName)
-	s.sb.WriteByte('`')
-	if alias != "" {
-		s.buildAs(alias)
-	}
-	return nil
-}
-
-func (s *Selector[T]) buildExpression(e Expression, isFirst bool) error {
-	switch e.(type) {
-	case Predicate:
-		if !isFirst {
-			s.sb.WriteByte('(')
+func (s *Selector[T]) buildCondAggregate(a CondAggregate) error {
+	if a.filter {
+		if !s.db.dialect.supportAggregateFilter() {
+			return errs.ErrUnsupportedAggregateFilter
 		}
-		p := e.(Predicate)
-		s.buildExpression(p.left, false)
-		s.sb.WriteString(fmt.Sprintf(" %s ", p.op))
-		s.buildExpression(p.right, false)
-		if !isFirst {
-			s.sb.WriteByte(')')
+		s.sb.WriteString("COUNT(*) FILTER (WHERE ")
+		if err := s.buildExpression(a.cond); err != nil {
+			return err
 		}
-
-	case Column:
-		s.sb.WriteString(fmt.Sprintf("`%s`", underscoreName(e.(Column).name)))
-	case value:
-		s.sb.WriteString("?")
-		s.addArgs(e.(value).val)
-	case Aggregate:
-		a := e.(Aggregate)
-		s.sb.WriteString(fmt.Sprintf("%s(`%s`)", a.fn, a.arg))
-
+		s.sb.WriteByte(')')
+	} else {
+		s.sb.WriteString("SUM(CASE WHEN ")
+		if err := s.buildExpression(a.cond); err != nil {
+			return err
+		}
+		s.sb.WriteString(" THEN 1 ELSE 0 END)")
 	}
-
-	return nil
+	return s.buildAs(a.alias)
 }
 
 // Where 用于构造 WHERE 查询条件。如果 ps 长度为 0，那么不会构造 WHERE 部分
@@ -246,6 +622,13 @@ func (s *Selector[T]) Where(ps ...Predicate) *Selector[T] {
 	return s
 }
 
+// WhereRaw 使用原生 SQL 作为查询条件，并且和已有的查询条件用 AND 连接。
+// 注意后面再调用 Where 会覆盖掉它
+func (s *Selector[T]) WhereRaw(sql string, args ...any) *Selector[T] {
+	s.where = append(s.where, Raw(sql, args...).AsPredicate())
+	return s
+}
+
 // GroupBy 设置 group by 子句
 func (s *Selector[T]) GroupBy(cols ...Column) *Selector[T] {
 	s.groupBy = cols
@@ -257,13 +640,17 @@ func (s *Selector[T]) Having(ps ...Predicate) *Selector[T] {
 	return s
 }
 
+// Offset 设置偏移量，Offset(0) 也会生成 OFFSET
 func (s *Selector[T]) Offset(offset int) *Selector[T] {
 	s.offset = offset
+	s.offsetSet = true
 	return s
 }
 
+// Limit 设置返回的行数，Limit(0) 会生成 LIMIT 0，也就是不返回任何数据
 func (s *Selector[T]) Limit(limit int) *Selector[T] {
 	s.limit = limit
+	s.limitSet = true
 	return s
 }
 
@@ -272,70 +659,351 @@ func (s *Selector[T]) OrderBy(orderBys ...OrderBy) *Selector[T] {
 	return s
 }
 
+// Union 和另外一个查询取并集
+// ORDER BY, LIMIT 和 OFFSET 作用于整个集合操作的结果
+func (s *Selector[T]) Union(other QueryBuilder) *Selector[T] {
+	return s.setOp(setOpUnion, other)
+}
+
+// UnionAll 和另外一个查询取并集，并且保留重复的行
+func (s *Selector[T]) UnionAll(other QueryBuilder) *Selector[T] {
+	return s.setOp(setOpUnionAll, other)
+}
+
+// Intersect 和另外一个查询取交集，MySQL 不支持
+func (s *Selector[T]) Intersect(other QueryBuilder) *Selector[T] {
+	return s.setOp(setOpIntersect, other)
+}
+
+// Except 和另外一个查询取差集，MySQL 不支持
+func (s *Selector[T]) Except(other QueryBuilder) *Selector[T] {
+	return s.setOp(setOpExcept, other)
+}
+
+func (s *Selector[T]) setOp(op string, other QueryBuilder) *Selector[T] {
+	s.setOps = append(s.setOps, setOperation{op: op, q: other})
+	return s
+}
+
+// RandomOrder 按照随机顺序排序，一般和 Limit 一起用于随机抽样
+// 它会追加在已有的排序条件之后，
+// 具体使用的函数取决于方言，例如 MySQL 是 RAND()，SQLite3 和 PostgreSQL 是 RANDOM()
+func (s *Selector[T]) RandomOrder() *Selector[T] {
+	s.orderBy = append(s.orderBy, OrderBy{random: true})
+	return s
+}
+
 func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
 	q, err := s.Build()
 	if err != nil {
 		return nil, err
 	}
-	// s.db 是我们定义的 DB
-	// s.db.db 则是 sql.DB
-	// 使用 QueryContext，从而和 GetMulti 能够复用处理结果集的代码
-	rows, err := s.db.db.QueryContext(ctx, q.SQL, q.Args...)
+	ctx, cancel, err := s.db.prepareContext(ctx, "Selector")
 	if err != nil {
 		return nil, err
 	}
+	defer cancel()
+	qc := &QueryContext{
+		Type:  "SELECT",
+		Query: q,
+		Model: s.model,
+	}
+	res := s.db.handle(ctx, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		tp, err := s.get(ctx, qc.Query)
+		return &QueryResult{Result: tp, Err: err}
+	})
+	tp, _ := res.Result.(*T)
+	return tp, res.Err
+}
+
+func (s *Selector[T]) get(ctx context.Context, q *Query) (*T, error) {
+	// s.sess 可能是 DB，也可能是 Tx
+	// 使用 QueryContext，从而和 GetMulti 能够复用处理结果集的代码
+	rows, err := s.sess.queryContext(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return nil, s.queryErr("SELECT", q, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
 
 	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, s.queryErr("SELECT", q, err)
+		}
 		return nil, ErrNoRows
 	}
 
 	tp := new(T)
-	meta, err := s.db.r.Get(tp)
-	if err != nil {
-		return nil, err
-	}
-	val := s.db.valCreator(tp, meta)
+	// Build 里面已经解析好了元数据
+	val := s.db.valCreator(tp, s.model)
 	err = val.SetColumns(rows)
 	return tp, err
 }
 
-func (s *Selector[T]) addArgs(args ...any) {
-	if s.args == nil {
-		s.args = make([]any, 0, 8)
+func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	q, err := s.Build()
+	if err != nil {
+		return nil, err
 	}
-	s.args = append(s.args, args...)
+	ctx, cancel, err := s.db.prepareContext(ctx, "Selector")
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	qc := &QueryContext{
+		Type:  "SELECT",
+		Query: q,
+		Model: s.model,
+	}
+	res := s.db.handle(ctx, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		tps, err := s.getMulti(ctx, qc.Query)
+		return &QueryResult{Result: tps, Err: err}
+	})
+	tps, _ := res.Result.([]*T)
+	return tps, res.Err
 }
 
-func (s *Selector[T]) buildAs(alias string) {
-	if alias != "" {
-		s.sb.WriteString(" AS ")
-		s.sb.WriteByte('`')
-		s.sb.WriteString(alias)
-		s.sb.WriteByte('`')
+func (s *Selector[T]) getMulti(ctx context.Context, q *Query) ([]*T, error) {
+	rows, err := s.sess.queryContext(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return nil, s.queryErr("SELECT", q, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]*T, 0, 8)
+	for rows.Next() {
+		tp := new(T)
+		// Build 里面已经解析好了元数据
+		val := s.db.valCreator(tp, s.model)
+		if err = val.SetColumns(rows); err != nil {
+			return nil, err
+		}
+		res = append(res, tp)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, s.queryErr("SELECT", q, err)
+	}
+	return res, nil
 }
 
-func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	var db sql.DB
+// GetMultiMap 执行查询，将每一行按照列名放进 map，不会使用模型的字段映射，
+// 所以适合计算出来的列或者使用了别名的列，例如报表查询。
+// NULL 对应的值是 nil，其它的值是驱动返回的原始类型，例如 MySQL 的字符串是 []byte
+func (s *Selector[T]) GetMultiMap(ctx context.Context) ([]map[string]any, error) {
 	q, err := s.Build()
 	if err != nil {
 		return nil, err
 	}
-	rows, err := db.QueryContext(ctx, q.SQL, q.Args...)
+	ctx, cancel, err := s.db.prepareContext(ctx, "Selector")
 	if err != nil {
 		return nil, err
 	}
+	defer cancel()
+	qc := &QueryContext{
+		Type:  "SELECT",
+		Query: q,
+		Model: s.model,
+	}
+	res := s.db.handle(ctx, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		maps, err := s.getMultiMap(ctx, qc.Query)
+		return &QueryResult{Result: maps, Err: err}
+	})
+	maps, _ := res.Result.([]map[string]any)
+	return maps, res.Err
+}
 
+func (s *Selector[T]) getMultiMap(ctx context.Context, q *Query) ([]map[string]any, error) {
+	rows, err := s.sess.queryContext(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return nil, s.queryErr("SELECT", q, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]map[string]any, 0, 8)
+	vals := make([]any, len(cols))
 	for rows.Next() {
-		// 在这里构造 []*T
+		// 扫描到 *any 的时候，database/sql 会复制 []byte，所以可以直接放进 map
+		dest := make([]any, len(cols))
+		for i := range vals {
+			dest[i] = &vals[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(cols))
+		for i, col := range cols {
+			row[col] = vals[i]
+		}
+		res = append(res, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, s.queryErr("SELECT", q, err)
+	}
+	return res, nil
+}
+
+// GetAs 执行 s 构造的查询，但是将结果映射到 R 上。
+// 适用于聚合查询这种结果集和模型对不上的场景，例如：
+//
+//	type AgeStats struct {
+//		Cnt    int64
+//		MaxAge int8
+//	}
+//	GetAs[AgeStats](ctx, NewSelector[User](db).Select(Count("Id").As("cnt"), Max("Age").As("max_age")))
+func GetAs[R any, T any](ctx context.Context, s *Selector[T]) (*R, error) {
+	q, err := s.Build()
+	if err != nil {
+		return nil, err
+	}
+	return RawQuery[R](s.sess, q.SQL, q.Args...).Get(ctx)
+}
+
+// GetMultiAs 和 GetAs 类似，一般和 GROUP BY 一起使用
+func GetMultiAs[R any, T any](ctx context.Context, s *Selector[T]) ([]*R, error) {
+	q, err := s.Build()
+	if err != nil {
+		return nil, err
+	}
+	return RawQuery[R](s.sess, q.SQL, q.Args...).GetMulti(ctx)
+}
+
+// Paginate 分页查询，返回第 page 页的数据，以及满足条件的总数
+// page 从 1 开始，size 是每页的数量。
+// 注意它会发起两次查询：一次 COUNT 查询，一次分页查询。
+// COUNT 查询会复用 WHERE 和 GROUP BY 部分，但是会去掉 ORDER BY, LIMIT 和 OFFSET
+func (s *Selector[T]) Paginate(ctx context.Context, page, size int) ([]*T, int64, error) {
+	if page < 1 || size < 1 {
+		return nil, 0, errs.NewErrInvalidPagination(page, size)
+	}
+	cq, err := s.buildCount()
+	if err != nil {
+		return nil, 0, err
 	}
+	// 两次查询使用同一个 ctx，所以默认超时时间是两次查询共享的
+	ctx, cancel, err := s.db.prepareContext(ctx, "Selector")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cancel()
+	qc := &QueryContext{
+		Type:  "SELECT",
+		Query: cq,
+		Model: s.model,
+	}
+	res := s.db.handle(ctx, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		var total int64
+		err := s.sess.queryRowContext(ctx, qc.Query.SQL, qc.Query.Args...).Scan(&total)
+		if err != nil {
+			err = s.queryErr("SELECT", qc.Query, err)
+		}
+		return &QueryResult{Result: total, Err: err}
+	})
+	if res.Err != nil {
+		return nil, 0, res.Err
+	}
+	total, _ := res.Result.(int64)
+	// 在副本上设置 LIMIT 和 OFFSET，不修改调用者的 Selector，
+	// 这样同一个 Selector 可以用来查询不同的页
+	p := *s
+	p.sb = strings.Builder{}
+	rows, err := p.Limit(size).Offset((page - 1) * size).GetMulti(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
 
-	panic("implement me")
+// Page 是 Paginate 的包装，page 小于 1 的时候当作第一页，
+// 适合直接使用前端传过来的页码。
+// 查询都由 Paginate 发起，不要在这里单独构造分页或者 COUNT 查询
+func (s *Selector[T]) Page(ctx context.Context, page, size int) ([]*T, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	rows, total, err := s.Paginate(ctx, page, size)
+	return rows, int(total), err
 }
 
-func NewSelector[T any](db *DB) *Selector[T] {
+// buildCount 构造统计总数的查询
+func (s *Selector[T]) buildCount() (*Query, error) {
+	sub := &Selector[T]{
+		builder:  builder{db: s.db, sess: s.sess, model: s.model},
+		table:    s.table,
+		where:    s.where,
+		groupBy:  s.groupBy,
+		having:   s.having,
+		unscoped: s.unscoped,
+	}
+	if len(s.groupBy) == 0 && !s.distinct {
+		sub.columns = []Selectable{Raw("COUNT(*)")}
+		return sub.Build()
+	}
+	// 有 GROUP BY 的时候，要统计的是分组的数量；
+	// 有 DISTINCT 的时候，要统计的是去重之后的数量，
+	// 所以要将原本的查询作为子查询
+	sub.distinct = s.distinct
+	if len(s.groupBy) > 0 {
+		sub.columns = make([]Selectable, 0, len(s.groupBy))
+		for _, c := range s.groupBy {
+			sub.columns = append(sub.columns, c)
+		}
+	} else {
+		sub.columns = s.columns
+		sub.excepts = s.excepts
+	}
+	q, err := sub.Build()
+	if err != nil {
+		return nil, err
+	}
+	sub.sb.Reset()
+	sub.sb.WriteString("SELECT COUNT(*) FROM (")
+	sub.sb.WriteString(strings.TrimSuffix(q.SQL, ";"))
+	sub.sb.WriteString(") AS ")
+	if err = sub.quote("t"); err != nil {
+		return nil, err
+	}
+	sub.sb.WriteByte(';')
+	return &Query{
+		SQL:  sub.sb.String(),
+		Args: q.Args,
+	}, nil
+}
+
+// buildWithArgOffset 实现 argOffsetBuilder，作为子查询的时候使用
+func (s *Selector[T]) buildWithArgOffset(offset int) (*Query, error) {
+	s.argOffset = offset
+	defer func() {
+		s.argOffset = 0
+	}()
+	return s.Build()
+}
+
+// AsSubquery 将当前的 Selector 作为子查询使用
+func (s *Selector[T]) AsSubquery(alias string) Subquery {
+	table := s.table
+	if table == nil {
+		table = Table{entity: new(T)}
+	}
+	return Subquery{
+		s:       s,
+		alias:   alias,
+		columns: s.columns,
+		table:   table,
+	}
+}
+
+func NewSelector[T any](sess Session) *Selector[T] {
 	return &Selector[T]{
-		db: db,
+		builder: newBuilder(sess),
 	}
 }
 
@@ -344,37 +1012,24 @@ type Selectable interface {
 }
 
 type OrderBy struct {
-	col   string
+	// col 可以是列，聚合函数或者原生表达式
+	col   Selectable
 	order string
+	// random 为 true 的时候按照随机顺序排序，忽略 col 和 order
+	random bool
 }
 
-func Asc(col string) OrderBy {
+// Asc 例如 Asc(C("Age"))，也可以按照聚合函数排序，例如 Asc(Count("Id"))
+func Asc(col Selectable) OrderBy {
 	return OrderBy{
 		col:   col,
 		order: "ASC",
 	}
 }
 
-func Desc(col string) OrderBy {
+func Desc(col Selectable) OrderBy {
 	return OrderBy{
 		col:   col,
 		order: "DESC",
 	}
 }
-
-// underscoreName 驼峰转字符串命名
-func underscoreName(tableName string) string {
-	var buf []byte
-	for i, v := range tableName {
-		if unicode.IsUpper(v) {
-			if i != 0 {
-				buf = append(buf, '_')
-			}
-			buf = append(buf, byte(unicode.ToLower(v)))
-		} else {
-			buf = append(buf, byte(v))
-		}
-
-	}
-	return string(buf)
-}
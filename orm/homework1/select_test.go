@@ -7,8 +7,12 @@ import (
 	"fmt"
 	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
 	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/valuer"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -22,21 +26,51 @@ func TestSelector_OrderBy(t *testing.T) {
 	}{
 		{
 			name: "column",
-			q:    NewSelector[TestModel](db).OrderBy(Asc("Age")),
+			q:    NewSelector[TestModel](db).OrderBy(Asc(C("Age"))),
 			wantQuery: &Query{
 				SQL: "SELECT * FROM `test_model` ORDER BY `age` ASC;",
 			},
 		},
 		{
 			name: "columns",
-			q:    NewSelector[TestModel](db).OrderBy(Asc("Age"), Desc("Id")),
+			q:    NewSelector[TestModel](db).OrderBy(Asc(C("Age")), Desc(C("Id"))),
 			wantQuery: &Query{
 				SQL: "SELECT * FROM `test_model` ORDER BY `age` ASC,`id` DESC;",
 			},
 		},
 		{
 			name:    "invalid column",
-			q:       NewSelector[TestModel](db).OrderBy(Asc("Invalid")),
+			q:       NewSelector[TestModel](db).OrderBy(Asc(C("Invalid"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			// 按照聚合函数排序
+			name: "aggregate",
+			q: NewSelector[TestModel](db).Select(C("Age"), Count("Id")).
+				GroupBy(C("Age")).OrderBy(Desc(Count("Id")), Asc(C("Age"))),
+			wantQuery: &Query{
+				SQL: "SELECT `age`,COUNT(`id`) FROM `test_model` GROUP BY `age` ORDER BY COUNT(`id`) DESC,`age` ASC;",
+			},
+		},
+		{
+			// 排序不使用聚合函数的别名
+			name: "aggregate with alias",
+			q: NewSelector[TestModel](db).Select(C("Age"), Sum("Age").As("total")).
+				GroupBy(C("Age")).OrderBy(Asc(Sum("Age").As("total"))),
+			wantQuery: &Query{
+				SQL: "SELECT `age`,SUM(`age`) AS `total` FROM `test_model` GROUP BY `age` ORDER BY SUM(`age`) ASC;",
+			},
+		},
+		{
+			name: "raw",
+			q:    NewSelector[TestModel](db).OrderBy(Desc(Raw("`age` + 1"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `age` + 1 DESC;",
+			},
+		},
+		{
+			name:    "invalid aggregate",
+			q:       NewSelector[TestModel](db).OrderBy(Desc(Count("Invalid"))),
 			wantErr: errs.NewErrUnknownField("Invalid"),
 		},
 	}
@@ -53,153 +87,368 @@ func TestSelector_OrderBy(t *testing.T) {
 	}
 }
 
-func TestSelector_OffsetLimit(t *testing.T) {
+func TestSelector_validate(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name    string
+		q       QueryBuilder
+		wantErr error
+	}{
+		{
+			name: "valid",
+			q: NewSelector[TestModel](db).Select(C("Id"), Avg("Age")).
+				Where(C("Age").GT(18)).GroupBy(C("FirstName")).OrderBy(Asc(C("Id"))),
+		},
+		{
+			name:    "one unknown field",
+			q:       NewSelector[TestModel](db).Where(C("Invalid").EQ(1)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "two unknown fields",
+			q: NewSelector[TestModel](db).Select(C("Id"), C("Invalid")).
+				Where(C("Age").GT(18).And(C("Unknown").EQ(1))),
+			wantErr: errs.NewErrUnknownFields([]string{"Invalid", "Unknown"}),
+		},
+		{
+			name: "all clauses",
+			q: NewSelector[TestModel](db).Select(Max("A")).
+				Where(Not(C("B").EQ(1))).GroupBy(C("C")).
+				Having(C("D").EQ(1)).OrderBy(Desc(C("E"))),
+			wantErr: errs.NewErrUnknownFields([]string{"A", "B", "C", "D", "E"}),
+		},
+		{
+			name: "duplicate unknown field",
+			q: NewSelector[TestModel](db).Select(C("Invalid")).
+				Where(C("Invalid").EQ(1)).OrderBy(Asc(C("Unknown"))),
+			wantErr: errs.NewErrUnknownFields([]string{"Invalid", "Unknown"}),
+		},
+		{
+			name:    "nil selectable",
+			q:       NewSelector[TestModel](db).Select(nil),
+			wantErr: errs.ErrNilSelectable,
+		},
+		{
+			name:    "nil selectable with others",
+			q:       NewSelector[TestModel](db).Select(C("Id"), nil, C("Invalid")),
+			wantErr: errs.ErrNilSelectable,
+		},
+		{
+			name:    "empty column",
+			q:       NewSelector[TestModel](db).Select(C("")),
+			wantErr: errs.ErrEmptyColumn,
+		},
+		{
+			// 空的列名优先于未知字段
+			name:    "empty column with unknown field",
+			q:       NewSelector[TestModel](db).Select(C("Invalid")).Where(C("").EQ(1)),
+			wantErr: errs.ErrEmptyColumn,
+		},
+		{
+			name:    "empty column with table",
+			q:       NewSelector[TestModel](db).Select(TableOf[TestModel]().C("")),
+			wantErr: errs.ErrEmptyColumn,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestSelector_validateJoinedError(t *testing.T) {
 	db := memoryDB(t)
+	_, err := NewSelector[TestModel](db).Select(C("Name")).
+		Where(C("Age").GT(18).And(C("Gender").EQ(1))).
+		OrderBy(Asc(C("CreateTime"))).Build()
+	require.Error(t, err)
+	// 每一个未知字段占一行
+	assert.Equal(t, "orm: 未知字段 Name\norm: 未知字段 Gender\norm: 未知字段 CreateTime", err.Error())
+	joined, ok := err.(interface{ Unwrap() []error })
+	require.True(t, ok)
+	assert.Equal(t, []error{
+		errs.NewErrUnknownField("Name"),
+		errs.NewErrUnknownField("Gender"),
+		errs.NewErrUnknownField("CreateTime"),
+	}, joined.Unwrap())
+}
+
+func TestSelector_ReadOnlyModel(t *testing.T) {
+	type UserView struct {
+		Id   int64
+		Name string
+	}
+	r := model.NewRegistry()
+	_, err := r.Register(&UserView{}, model.WithTableName("user_view"), model.WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithRegistry(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只读模型依旧可以查询
+	query, err := NewSelector[UserView](db).Where(C("Id").EQ(1)).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "SELECT * FROM `user_view` WHERE `id` = ?;",
+		Args: []any{1},
+	}, query)
+}
+
+func TestSelector_RegisteredTableName(t *testing.T) {
+	r := model.NewRegistry()
+	_, err := r.Register(&TestModel{}, model.WithTableName("t_test_model"))
+	require.NoError(t, err)
+	db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithRegistry(r))
+	require.NoError(t, err)
+
 	testCases := []struct {
 		name      string
 		q         QueryBuilder
 		wantQuery *Query
-		wantErr   error
 	}{
 		{
-			name: "offset only",
-			q:    NewSelector[TestModel](db).Offset(10),
+			// 没有调用 From 的时候使用注册的表名
+			name: "no from",
+			q:    NewSelector[TestModel](db).Where(C("Id").EQ(1)),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` OFFSET ?;",
-				Args: []any{10},
+				SQL:  "SELECT * FROM `t_test_model` WHERE `id` = ?;",
+				Args: []any{1},
 			},
 		},
 		{
-			name: "limit only",
-			q:    NewSelector[TestModel](db).Limit(10),
+			name: "table",
+			q:    NewSelector[TestModel](db).From(TableOf[TestModel]()).Select(TableOf[TestModel]().C("Id")),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` LIMIT ?;",
-				Args: []any{10},
+				SQL: "SELECT `t_test_model`.`id` FROM `t_test_model`;",
 			},
 		},
 		{
-			name: "limit offset",
-			q:    NewSelector[TestModel](db).Limit(20).Offset(10),
+			// From 优先
+			name: "from",
+			q:    NewSelector[TestModel](db).From(Raw("`test_model_v2`")),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` LIMIT ? OFFSET ?;",
-				Args: []any{20, 10},
+				SQL: "SELECT * FROM `test_model_v2`;",
+			},
+		},
+		{
+			name: "deleter",
+			q:    NewDeleter[TestModel](db).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM `t_test_model` WHERE `id` = ?;",
+				Args: []any{1},
 			},
 		},
 	}
-
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			query, err := tc.q.Build()
-			assert.Equal(t, tc.wantErr, err)
-			if err != nil {
-				return
-			}
+			require.NoError(t, err)
 			assert.Equal(t, tc.wantQuery, query)
 		})
 	}
 }
 
-func TestSelector_Having(t *testing.T) {
+func TestSelector_Debug(t *testing.T) {
 	db := memoryDB(t)
+	testCases := []struct {
+		name    string
+		s       *Selector[TestModel]
+		wantRes string
+	}{
+		{
+			name:    "valid",
+			s:       NewSelector[TestModel](db).Where(C("Id").EQ(1)),
+			wantRes: "SELECT * FROM `test_model` WHERE `id` = ?;",
+		},
+		{
+			name:    "invalid column",
+			s:       NewSelector[TestModel](db).Select(C("Invalid")),
+			wantRes: errs.NewErrUnknownField("Invalid").Error(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantRes, tc.s.Debug())
+			// 重复调用结果不变
+			assert.Equal(t, tc.wantRes, tc.s.Debug())
+		})
+	}
+}
+
+func TestSelector_RandomOrder(t *testing.T) {
 	testCases := []struct {
 		name      string
-		q         QueryBuilder
+		dialect   Dialect
+		q         func(db *DB) QueryBuilder
 		wantQuery *Query
-		wantErr   error
 	}{
 		{
-			// 调用了，但是啥也没传
-			name: "none",
-			q:    NewSelector[TestModel](db).GroupBy(C("Age")).Having(),
+			name:    "mysql",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).RandomOrder().Limit(10)
+			},
 			wantQuery: &Query{
-				SQL: "SELECT * FROM `test_model` GROUP BY `age`;",
+				SQL:  "SELECT * FROM `test_model` ORDER BY RAND() LIMIT ?;",
+				Args: []any{10},
 			},
 		},
 		{
-			// 单个条件
-			name: "single",
-			q: NewSelector[TestModel](db).GroupBy(C("Age")).
-				Having(C("FirstName").EQ("Deng")),
+			name:    "sqlite3",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).RandomOrder().Limit(10)
+			},
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` GROUP BY `age` HAVING `first_name` = ?;",
-				Args: []any{"Deng"},
+				SQL:  "SELECT * FROM `test_model` ORDER BY RANDOM() LIMIT ?;",
+				Args: []any{10},
 			},
 		},
 		{
-			// 多个条件
-			name: "multiple",
-			q: NewSelector[TestModel](db).GroupBy(C("Age")).
-				Having(C("FirstName").EQ("Deng"), C("LastName").EQ("Ming")),
+			name:    "postgres",
+			dialect: Postgres,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).RandomOrder().Limit(10)
+			},
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` GROUP BY `age` HAVING (`first_name` = ?) AND (`last_name` = ?);",
-				Args: []any{"Deng", "Ming"},
+				SQL:  "SELECT * FROM \"test_model\" ORDER BY RANDOM() LIMIT $1;",
+				Args: []any{10},
 			},
 		},
 		{
-			// 聚合函数
-			name: "avg",
-			q: NewSelector[TestModel](db).GroupBy(C("Age")).
-				Having(Avg("Age").EQ(18)),
+			// 追加在已有的排序条件之后
+			name:    "after order by",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).
+					Where(C("Age").GT(18)).OrderBy(Desc(C("Age"))).RandomOrder()
+			},
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` GROUP BY `age` HAVING AVG(`age`) = ?;",
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? ORDER BY `age` DESC,RAND();",
 				Args: []any{18},
 			},
 		},
 	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			query, err := tc.q.Build()
-			assert.Equal(t, tc.wantErr, err)
+			db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory",
+				DBWithDialect(tc.dialect))
 			if err != nil {
-				return
+				t.Fatal(err)
+			}
+			query, err := tc.q(db).Build()
+			if err != nil {
+				t.Fatal(err)
 			}
 			assert.Equal(t, tc.wantQuery, query)
 		})
 	}
 }
 
-func TestSelector_GroupBy(t *testing.T) {
-	db := memoryDB(t)
+func TestSelector_SetOperation(t *testing.T) {
 	testCases := []struct {
 		name      string
-		q         QueryBuilder
+		dialect   Dialect
+		q         func(db *DB) QueryBuilder
 		wantQuery *Query
 		wantErr   error
 	}{
 		{
-			// 调用了，但是啥也没传
-			name: "none",
-			q:    NewSelector[TestModel](db).GroupBy(),
+			name:    "mysql union",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Where(C("Age").LT(18)).
+					Union(NewSelector[TestModel](db).Where(C("Age").GT(60)))
+			},
 			wantQuery: &Query{
-				SQL: "SELECT * FROM `test_model`;",
+				SQL:  "SELECT * FROM `test_model` WHERE `age` < ? UNION SELECT * FROM `test_model` WHERE `age` > ?;",
+				Args: []any{18, 60},
 			},
 		},
 		{
-			// 单个
-			name: "single",
-			q:    NewSelector[TestModel](db).GroupBy(C("Age")),
+			// 原生查询可能没有分号，不能去掉最后一个字符
+			name:    "raw query without semicolon",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Where(C("Age").LT(18)).
+					Union(RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` = 1"))
+			},
 			wantQuery: &Query{
-				SQL: "SELECT * FROM `test_model` GROUP BY `age`;",
+				SQL:  "SELECT * FROM `test_model` WHERE `age` < ? UNION SELECT * FROM `test_model` WHERE `id` = 1;",
+				Args: []any{18},
 			},
 		},
 		{
-			// 多个
-			name: "multiple",
-			q:    NewSelector[TestModel](db).GroupBy(C("Age"), C("FirstName")),
+			name:    "raw query with semicolon",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).
+					UnionAll(RawQuery[TestModel](db, "SELECT * FROM `test_model` WHERE `id` = ?;", 1))
+			},
 			wantQuery: &Query{
-				SQL: "SELECT * FROM `test_model` GROUP BY `age`,`first_name`;",
+				SQL:  "SELECT * FROM `test_model` UNION ALL SELECT * FROM `test_model` WHERE `id` = ?;",
+				Args: []any{1},
 			},
 		},
 		{
-			// 不存在
-			name:    "invalid column",
-			q:       NewSelector[TestModel](db).GroupBy(C("Invalid")),
-			wantErr: errs.NewErrUnknownField("Invalid"),
+			name:    "mysql intersect",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).
+					Intersect(NewSelector[TestModel](db))
+			},
+			wantErr: errs.NewErrUnsupportedSetOperation("INTERSECT"),
+		},
+		{
+			name:    "mysql except",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).
+					Except(NewSelector[TestModel](db))
+			},
+			wantErr: errs.NewErrUnsupportedSetOperation("EXCEPT"),
+		},
+		{
+			name:    "postgres intersect",
+			dialect: Postgres,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").GT(18)).
+					Intersect(NewSelector[TestModel](db).Select(C("Id")).Where(C("FirstName").EQ("Tom")))
+			},
+			wantQuery: &Query{
+				SQL:  `SELECT "id" FROM "test_model" WHERE "age" > $1 INTERSECT SELECT "id" FROM "test_model" WHERE "first_name" = $2;`,
+				Args: []any{18, "Tom"},
+			},
+		},
+		{
+			// ORDER BY 和 LIMIT 作用于整个结果
+			name:    "sqlite3 except",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(C("Id")).
+					Except(NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").LT(18))).
+					OrderBy(Asc(C("Id"))).Limit(10)
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT `id` FROM `test_model` EXCEPT SELECT `id` FROM `test_model` WHERE `age` < ? ORDER BY `id` ASC LIMIT ?;",
+				Args: []any{18, 10},
+			},
 		},
 	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			query, err := tc.q.Build()
+			db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory",
+				DBWithDialect(tc.dialect))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := tc.q(db).Build()
 			assert.Equal(t, tc.wantErr, err)
 			if err != nil {
 				return
@@ -207,9 +456,15 @@ func TestSelector_GroupBy(t *testing.T) {
 			assert.Equal(t, tc.wantQuery, query)
 		})
 	}
+
+	// 空的原生查询不会因为去掉分号而 panic
+	assert.NotPanics(t, func() {
+		db := memoryDB(t)
+		_, _ = NewSelector[TestModel](db).Union(RawQuery[TestModel](db, "")).Build()
+	})
 }
 
-func TestSelector_Select(t *testing.T) {
+func TestSelector_OffsetLimit(t *testing.T) {
 	db := memoryDB(t)
 	testCases := []struct {
 		name      string
@@ -218,60 +473,56 @@ func TestSelector_Select(t *testing.T) {
 		wantErr   error
 	}{
 		{
-			// 没有指定
-			name: "all",
-			q:    NewSelector[TestModel](db),
+			// MySQL 不能单独使用 OFFSET
+			name: "offset only",
+			q:    NewSelector[TestModel](db).Offset(10),
 			wantQuery: &Query{
-				SQL: "SELECT * FROM `test_model`;",
+				SQL:  "SELECT * FROM `test_model` LIMIT 18446744073709551615 OFFSET ?;",
+				Args: []any{10},
 			},
 		},
 		{
-			name:    "invalid column",
-			q:       NewSelector[TestModel](db).Select(Avg("Invalid")),
-			wantErr: errs.NewErrUnknownField("Invalid"),
-		},
-		{
-			name: "partial columns",
-			q:    NewSelector[TestModel](db).Select(C("Id"), C("FirstName")),
+			name: "offset 0",
+			q:    NewSelector[TestModel](db).Offset(0),
 			wantQuery: &Query{
-				SQL: "SELECT `id`,`first_name` FROM `test_model`;",
+				SQL:  "SELECT * FROM `test_model` LIMIT 18446744073709551615 OFFSET ?;",
+				Args: []any{0},
 			},
 		},
 		{
-			name: "avg",
-			q:    NewSelector[TestModel](db).Select(Avg("Age")),
+			name: "limit 0",
+			q:    NewSelector[TestModel](db).Limit(0),
 			wantQuery: &Query{
-				SQL: "SELECT AVG(`age`) FROM `test_model`;",
+				SQL:  "SELECT * FROM `test_model` LIMIT ?;",
+				Args: []any{0},
 			},
 		},
 		{
-			name: "raw expression",
-			q:    NewSelector[TestModel](db).Select(Raw("COUNT(DISTINCT `first_name`)")),
+			name: "limit 0 offset 0",
+			q:    NewSelector[TestModel](db).Limit(0).Offset(0),
 			wantQuery: &Query{
-				SQL: "SELECT COUNT(DISTINCT `first_name`) FROM `test_model`;",
+				SQL:  "SELECT * FROM `test_model` LIMIT ? OFFSET ?;",
+				Args: []any{0, 0},
 			},
 		},
-		// 别名
 		{
-			name: "alias",
-			q: NewSelector[TestModel](db).
-				Select(C("Id").As("my_id"),
-					Avg("Age").As("avg_age")),
+			name: "limit only",
+			q:    NewSelector[TestModel](db).Limit(10),
 			wantQuery: &Query{
-				SQL: "SELECT `id` AS `my_id`,AVG(`age`) AS `avg_age` FROM `test_model`;",
+				SQL:  "SELECT * FROM `test_model` LIMIT ?;",
+				Args: []any{10},
 			},
 		},
-		// WHERE 忽略别名
 		{
-			name: "where ignore alias",
-			q: NewSelector[TestModel](db).
-				Where(C("Id").As("my_id").LT(100)),
+			name: "limit offset",
+			q:    NewSelector[TestModel](db).Limit(20).Offset(10),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE `id` < ?;",
-				Args: []any{100},
+				SQL:  "SELECT * FROM `test_model` LIMIT ? OFFSET ?;",
+				Args: []any{20, 10},
 			},
 		},
 	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			query, err := tc.q.Build()
@@ -284,63 +535,1914 @@ func TestSelector_Select(t *testing.T) {
 	}
 }
 
-func TestSelector_Build(t *testing.T) {
-	db := memoryDB(t)
+func TestSelector_OffsetWithoutLimit(t *testing.T) {
 	testCases := []struct {
 		name      string
-		q         QueryBuilder
+		opts      []DBOption
 		wantQuery *Query
-		wantErr   error
 	}{
 		{
-			// From 都不调用
-			name: "no from",
-			q:    NewSelector[TestModel](db),
-			wantQuery: &Query{
-				SQL: "SELECT * FROM `test_model`;",
-			},
-		},
-		{
-			// 调用 FROM
-			name: "with from",
-			q:    NewSelector[TestModel](db).From("`test_model_t`"),
+			name: "mysql",
+			opts: []DBOption{DBWithDialect(MySQL)},
 			wantQuery: &Query{
-				SQL: "SELECT * FROM `test_model_t`;",
+				SQL:  "SELECT * FROM `test_model` LIMIT 18446744073709551615 OFFSET ?;",
+				Args: []any{10},
 			},
 		},
 		{
-			// 调用 FROM，但是传入空字符串
-			name: "empty from",
-			q:    NewSelector[TestModel](db).From(""),
+			name: "sqlite3",
+			opts: []DBOption{DBWithDialect(SQLite3)},
 			wantQuery: &Query{
-				SQL: "SELECT * FROM `test_model`;",
+				SQL:  "SELECT * FROM `test_model` LIMIT -1 OFFSET ?;",
+				Args: []any{10},
 			},
 		},
 		{
-			// 调用 FROM，同时出入看了 DB
-			name: "with db",
-			q:    NewSelector[TestModel](db).From("`test_db`.`test_model`"),
+			// PostgreSQL 可以单独使用 OFFSET
+			name: "postgres",
+			opts: []DBOption{DBWithDialect(Postgres)},
 			wantQuery: &Query{
-				SQL: "SELECT * FROM `test_db`.`test_model`;",
+				SQL:  `SELECT * FROM "test_model" OFFSET $1;`,
+				Args: []any{10},
 			},
 		},
 		{
-			// 单一简单条件
-			name: "single and simple predicate",
-			q: NewSelector[TestModel](db).From("`test_model_t`").
-				Where(C("Id").EQ(1)),
+			name: "inline",
+			opts: []DBOption{DBWithDialect(SQLite3), DBWithInlineLimitOffset(true)},
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model_t` WHERE `id` = ?;",
-				Args: []any{1},
+				SQL: "SELECT * FROM `test_model` LIMIT -1 OFFSET 10;",
 			},
 		},
-		{
-			// 多个 predicate
-			name: "multiple predicates",
-			q: NewSelector[TestModel](db).
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := NewSelector[TestModel](db).Offset(10).Build()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_InlineLimitOffset(t *testing.T) {
+	db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory",
+		DBWithInlineLimitOffset(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "limit offset",
+			q:    NewSelector[TestModel](db).Limit(10).Offset(20),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` LIMIT 10 OFFSET 20;",
+			},
+		},
+		{
+			// 其余部分依旧使用占位符
+			name: "with where",
+			q:    NewSelector[TestModel](db).Where(C("Age").GT(18)).Limit(10),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? LIMIT 10;",
+				Args: []any{18},
+			},
+		},
+		{
+			name:    "negative limit",
+			q:       NewSelector[TestModel](db).Limit(-1),
+			wantErr: errs.NewErrInvalidLimitOffset("LIMIT", -1),
+		},
+		{
+			name:    "negative offset",
+			q:       NewSelector[TestModel](db).Offset(-1),
+			wantErr: errs.NewErrInvalidLimitOffset("OFFSET", -1),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Quoting(t *testing.T) {
+	type InvalidColumn struct {
+		FirstName string `orm:"column=first-name"`
+	}
+	testCases := []struct {
+		name      string
+		opts      []DBOption
+		q         func(db *DB) QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "mysql",
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(C("Id"), Avg("Age").As("avg_age")).
+					Where(C("Age").GT(18))
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT `id`,AVG(`age`) AS `avg_age` FROM `test_model` WHERE `age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "postgres",
+			opts: []DBOption{DBWithDialect(Postgres)},
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(C("Id"), Avg("Age").As("avg_age")).
+					Where(C("Age").GT(18))
+			},
+			wantQuery: &Query{
+				SQL:  `SELECT "id",AVG("age") AS "avg_age" FROM "test_model" WHERE "age" > $1;`,
+				Args: []any{18},
+			},
+		},
+		{
+			name: "no quoting",
+			opts: []DBOption{DBWithNoQuoting()},
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(C("Id"), Avg("Age").As("avg_age")).
+					Where(C("Age").GT(18)).OrderBy(Desc(C("FirstName")))
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT id,AVG(age) AS avg_age FROM test_model WHERE age > ? ORDER BY first_name DESC;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "no quoting invalid column",
+			opts: []DBOption{DBWithNoQuoting()},
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[InvalidColumn](db).Select(C("FirstName"))
+			},
+			wantErr: errs.NewErrInvalidIdentifier("first-name"),
+		},
+		{
+			name: "no quoting invalid alias",
+			opts: []DBOption{DBWithNoQuoting()},
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(C("Id").As("1id"))
+			},
+			wantErr: errs.NewErrInvalidIdentifier("1id"),
+		},
+		{
+			name: "no quoting injection",
+			opts: []DBOption{DBWithNoQuoting()},
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(Max("Age").As("a; DROP TABLE t"))
+			},
+			wantErr: errs.NewErrInvalidIdentifier("a; DROP TABLE t"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := tc.q(db).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Placeholder(t *testing.T) {
+	testCases := []struct {
+		name         string
+		q            func(db *DB) QueryBuilder
+		wantMySQL    string
+		wantPostgres string
+		wantArgs     []any
+	}{
+		{
+			name: "where limit offset",
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).
+					Where(C("Age").GT(18), C("FirstName").In("Tom", "Jerry")).
+					Limit(10).Offset(20)
+			},
+			wantMySQL:    "SELECT * FROM `test_model` WHERE `age` > ? AND `first_name` IN (?,?) LIMIT ? OFFSET ?;",
+			wantPostgres: `SELECT * FROM "test_model" WHERE "age" > $1 AND "first_name" IN ($2,$3) LIMIT $4 OFFSET $5;`,
+			wantArgs:     []any{18, "Tom", "Jerry", 10, 20},
+		},
+		{
+			// 子查询的占位符接着外层查询的序号
+			name: "subquery",
+			q: func(db *DB) QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("Id")).
+					Where(C("Age").Between(18, 30)).AsSubquery("sub")
+				return NewSelector[TestModel](db).
+					Where(C("FirstName").EQ("Tom"), C("Id").In(sub), C("LastName").IsNotNull()).
+					Limit(1)
+			},
+			wantMySQL: "SELECT * FROM `test_model` WHERE `first_name` = ? AND `id` IN " +
+				"(SELECT `id` FROM `test_model` WHERE `age` BETWEEN ? AND ?) AND `last_name` IS NOT NULL LIMIT ?;",
+			wantPostgres: `SELECT * FROM "test_model" WHERE "first_name" = $1 AND "id" IN ` +
+				`(SELECT "id" FROM "test_model" WHERE "age" BETWEEN $2 AND $3) AND "last_name" IS NOT NULL LIMIT $4;`,
+			wantArgs: []any{"Tom", 18, 30, 1},
+		},
+		{
+			// FROM 子查询的参数在 WHERE 的参数之前
+			name: "from subquery",
+			q: func(db *DB) QueryBuilder {
+				sub := NewSelector[TestModel](db).Where(C("Age").GT(18)).AsSubquery("t")
+				return NewSelector[TestModel](db).From(sub).Where(C("Id").LT(100))
+			},
+			wantMySQL:    "SELECT * FROM (SELECT * FROM `test_model` WHERE `age` > ?) AS `t` WHERE `id` < ?;",
+			wantPostgres: `SELECT * FROM (SELECT * FROM "test_model" WHERE "age" > $1) AS "t" WHERE "id" < $2;`,
+			wantArgs:     []any{18, 100},
+		},
+		{
+			name: "union",
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").GT(18)).
+					Union(NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").LT(10)))
+			},
+			wantMySQL:    "SELECT `id` FROM `test_model` WHERE `age` > ? UNION SELECT `id` FROM `test_model` WHERE `age` < ?;",
+			wantPostgres: `SELECT "id" FROM "test_model" WHERE "age" > $1 UNION SELECT "id" FROM "test_model" WHERE "age" < $2;`,
+			wantArgs:     []any{18, 10},
+		},
+		{
+			// 原生表达式里面的 ? 和其它参数一起编号
+			name: "raw",
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(C("Id"), Raw("age + ?", 1)).
+					From(Raw("(SELECT * FROM test_model WHERE age > ?) AS t", 18)).
+					Where(C("FirstName").EQ("Tom")).
+					WhereRaw("age < ? OR age = ?", 60, 99).
+					Limit(10)
+			},
+			wantMySQL: "SELECT `id`,age + ? FROM (SELECT * FROM test_model WHERE age > ?) AS t " +
+				"WHERE `first_name` = ? AND (age < ? OR age = ?) LIMIT ?;",
+			wantPostgres: `SELECT "id",age + $1 FROM (SELECT * FROM test_model WHERE age > $2) AS t ` +
+				`WHERE "first_name" = $3 AND (age < $4 OR age = $5) LIMIT $6;`,
+			wantArgs: []any{1, 18, "Tom", 60, 99, 10},
+		},
+		{
+			name: "update",
+			q: func(db *DB) QueryBuilder {
+				return NewUpdater[TestModel](db).
+					Set(Assign("Age", C("Age").Add(1)), Assign("FirstName", "Tom")).
+					Where(C("Id").EQ(12))
+			},
+			wantMySQL:    "UPDATE `test_model` SET `age`=`age` + ?,`first_name`=? WHERE `id` = ?;",
+			wantPostgres: `UPDATE "test_model" SET "age"="age" + $1,"first_name"=$2 WHERE "id" = $3;`,
+			wantArgs:     []any{1, "Tom", 12},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mysqlDB, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithDialect(MySQL))
+			require.NoError(t, err)
+			query, err := tc.q(mysqlDB).Build()
+			require.NoError(t, err)
+			assert.Equal(t, &Query{SQL: tc.wantMySQL, Args: tc.wantArgs}, query)
+
+			pgDB, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithDialect(Postgres))
+			require.NoError(t, err)
+			query, err = tc.q(pgDB).Build()
+			require.NoError(t, err)
+			assert.Equal(t, &Query{SQL: tc.wantPostgres, Args: tc.wantArgs}, query)
+		})
+	}
+}
+
+func TestSelector_CondAggregate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		dialect   Dialect
+		q         func(db *DB) QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "count if",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).
+					Select(Count("Id"), CountIf(C("Age").GT(18)).As("adult")).
+					Where(C("FirstName").EQ("Tom"))
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT COUNT(`id`),SUM(CASE WHEN `age` > ? THEN 1 ELSE 0 END) AS `adult` FROM `test_model` WHERE `first_name` = ?;",
+				Args: []any{18, "Tom"},
+			},
+		},
+		{
+			name:    "count if postgres",
+			dialect: Postgres,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).
+					Select(CountIf(C("Age").GT(18).And(C("Age").LT(60))))
+			},
+			wantQuery: &Query{
+				SQL:  `SELECT SUM(CASE WHEN "age" > $1 AND "age" < $2 THEN 1 ELSE 0 END) FROM "test_model";`,
+				Args: []any{18, 60},
+			},
+		},
+		{
+			name:    "filter postgres",
+			dialect: Postgres,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).
+					Select(CountFilter(C("Age").GT(18)).As("adult"), CountFilter(C("Age").LT(18)).As("child"))
+			},
+			wantQuery: &Query{
+				SQL:  `SELECT COUNT(*) FILTER (WHERE "age" > $1) AS "adult",COUNT(*) FILTER (WHERE "age" < $2) AS "child" FROM "test_model";`,
+				Args: []any{18, 18},
+			},
+		},
+		{
+			name:    "filter mysql",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(CountFilter(C("Age").GT(18)))
+			},
+			wantErr: errs.ErrUnsupportedAggregateFilter,
+		},
+		{
+			name:    "filter sqlite3",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(CountFilter(C("Age").GT(18)))
+			},
+			wantErr: errs.ErrUnsupportedAggregateFilter,
+		},
+		{
+			name:    "unknown field",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Select(CountIf(C("Invalid").GT(18)))
+			},
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory",
+				DBWithDialect(tc.dialect))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := tc.q(db).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Lock(t *testing.T) {
+	testCases := []struct {
+		name         string
+		q            func(db *DB) QueryBuilder
+		wantMySQL    string
+		wantPostgres string
+		wantArgs     []any
+	}{
+		{
+			name: "for update",
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Where(C("Id").EQ(1)).ForUpdate()
+			},
+			wantMySQL:    "SELECT * FROM `test_model` WHERE `id` = ? FOR UPDATE;",
+			wantPostgres: `SELECT * FROM "test_model" WHERE "id" = $1 FOR UPDATE;`,
+			wantArgs:     []any{1},
+		},
+		{
+			name: "for share",
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Where(C("Id").EQ(1)).ForShare()
+			},
+			wantMySQL:    "SELECT * FROM `test_model` WHERE `id` = ? LOCK IN SHARE MODE;",
+			wantPostgres: `SELECT * FROM "test_model" WHERE "id" = $1 FOR SHARE;`,
+			wantArgs:     []any{1},
+		},
+		{
+			// 锁定读在 ORDER BY, LIMIT 和 OFFSET 之后
+			name: "after limit offset",
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Where(C("Age").GT(18)).
+					OrderBy(Asc(C("Id"))).Limit(10).Offset(20).ForUpdate()
+			},
+			wantMySQL:    "SELECT * FROM `test_model` WHERE `age` > ? ORDER BY `id` ASC LIMIT ? OFFSET ? FOR UPDATE;",
+			wantPostgres: `SELECT * FROM "test_model" WHERE "age" > $1 ORDER BY "id" ASC LIMIT $2 OFFSET $3 FOR UPDATE;`,
+			wantArgs:     []any{18, 10, 20},
+		},
+		{
+			// 后调用的覆盖先调用的
+			name: "override",
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).ForUpdate().ForShare()
+			},
+			wantMySQL:    "SELECT * FROM `test_model` LOCK IN SHARE MODE;",
+			wantPostgres: `SELECT * FROM "test_model" FOR SHARE;`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mysqlDB, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithDialect(MySQL))
+			require.NoError(t, err)
+			query, err := tc.q(mysqlDB).Build()
+			require.NoError(t, err)
+			assert.Equal(t, &Query{SQL: tc.wantMySQL, Args: tc.wantArgs}, query)
+
+			pgDB, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithDialect(Postgres))
+			require.NoError(t, err)
+			query, err = tc.q(pgDB).Build()
+			require.NoError(t, err)
+			assert.Equal(t, &Query{SQL: tc.wantPostgres, Args: tc.wantArgs}, query)
+		})
+	}
+
+	// SQLite3 不支持行锁
+	db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithDialect(SQLite3))
+	require.NoError(t, err)
+	_, err = NewSelector[TestModel](db).ForUpdate().Build()
+	assert.Equal(t, errs.NewErrUnsupportedLock("FOR UPDATE"), err)
+}
+
+func TestSelector_SoftDelete(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "no where",
+			q:    NewSelector[SoftDeleteModel](db),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `soft_delete_model` WHERE `deleted_at` IS NULL;",
+			},
+		},
+		{
+			// 用户的条件需要加上括号
+			name: "or",
+			q: NewSelector[SoftDeleteModel](db).
+				Where(C("Id").EQ(1).Or(C("Name").EQ("Tom"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `soft_delete_model` WHERE (`id` = ? OR `name` = ?) AND `deleted_at` IS NULL;",
+				Args: []any{1, "Tom"},
+			},
+		},
+		{
+			name: "unscoped",
+			q:    NewSelector[SoftDeleteModel](db).Where(C("Id").EQ(1)).Unscoped(),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `soft_delete_model` WHERE `id` = ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "table",
+			q:    NewSelector[SoftDeleteModel](db).From(TableOf[SoftDeleteModel]().As("m")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `soft_delete_model` AS `m` WHERE `m`.`deleted_at` IS NULL;",
+			},
+		},
+		{
+			// JOIN 不会自动过滤
+			name: "join",
+			q: func() QueryBuilder {
+				m1 := TableOf[SoftDeleteModel]().As("m1")
+				m2 := TableOf[SoftDeleteModel]().As("m2")
+				return NewSelector[SoftDeleteModel](db).From(m1.Join(m2).On(m1.C("Id").EQ(m2.C("Id"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `soft_delete_model` AS `m1` JOIN `soft_delete_model` AS `m2` ON `m1`.`id` = `m2`.`id`;",
+			},
+		},
+		{
+			// 没有开启软删除的模型
+			name: "not soft delete",
+			q:    NewSelector[TestModel](db).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` = ?;",
+				Args: []any{1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+
+	// COUNT 查询同样要过滤
+	q, err := NewSelector[SoftDeleteModel](db).Where(C("Id").GT(1)).buildCount()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "SELECT COUNT(*) FROM `soft_delete_model` WHERE `id` > ? AND `deleted_at` IS NULL;",
+		Args: []any{1},
+	}, q)
+
+	// 注册的时候开启软删除
+	type RegisteredSoftDelete struct {
+		Id     int64
+		Ctime  sql.NullTime
+		Delete sql.NullTime
+	}
+	r := model.NewRegistry()
+	_, err = r.Register(&RegisteredSoftDelete{}, model.WithSoftDelete("Delete"))
+	require.NoError(t, err)
+	db, err = Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithRegistry(r))
+	require.NoError(t, err)
+	q, err = NewSelector[RegisteredSoftDelete](db).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `registered_soft_delete` WHERE `delete` IS NULL;", q.SQL)
+}
+
+func TestSelector_WhereColumnName(t *testing.T) {
+	type CustomColumn struct {
+		Id        int64  `orm:"column=user_id"`
+		FirstName string `orm:"column=name"`
+		Age       int8
+	}
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "where",
+			q:    NewSelector[CustomColumn](db).Where(C("FirstName").EQ("Tom").And(C("Age").GT(18))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `custom_column` WHERE `name` = ? AND `age` > ?;",
+				Args: []any{"Tom", 18},
+			},
+		},
+		{
+			name: "having",
+			q: NewSelector[CustomColumn](db).Select(C("FirstName")).
+				GroupBy(C("FirstName")).Having(C("Id").GT(10)),
+			wantQuery: &Query{
+				SQL:  "SELECT `name` FROM `custom_column` GROUP BY `name` HAVING `user_id` > ?;",
+				Args: []any{10},
+			},
+		},
+		{
+			// 使用列名而不是字段名
+			name:    "column name",
+			q:       NewSelector[CustomColumn](db).Where(C("name").EQ("Tom")),
+			wantErr: errs.NewErrUnknownField("name"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_RegisteredColumnName(t *testing.T) {
+	r := model.NewRegistry()
+	_, err := r.Register(&TestModel{}, model.WithColumnName("FirstName", "f_name"))
+	require.NoError(t, err)
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB, DBWithRegistry(r))
+	require.NoError(t, err)
+
+	query, err := NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).
+		Where(C("FirstName").EQ("Tom")).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "SELECT `id`,`f_name` FROM `test_model` WHERE `f_name` = ?;",
+		Args: []any{"Tom"},
+	}, query)
+
+	// 结果集里面的列也使用新的列名
+	mock.ExpectQuery(regexp.QuoteMeta(query.SQL)).WithArgs("Tom").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "f_name"}).AddRow(1, "Tom"))
+	res, err := NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).
+		Where(C("FirstName").EQ("Tom")).Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1, FirstName: "Tom"}, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = r.Register(&TestModel{}, model.WithColumnName("Unknown", "f_name"))
+	assert.Equal(t, errs.NewErrUnknownField("Unknown"), err)
+}
+
+func TestSelector_Distinct(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "distinct all",
+			q:    NewSelector[TestModel](db).Distinct(),
+			wantQuery: &Query{
+				SQL: "SELECT DISTINCT * FROM `test_model`;",
+			},
+		},
+		{
+			name: "distinct columns",
+			q: NewSelector[TestModel](db).Distinct().
+				Select(C("FirstName"), C("LastName").As("ln")).Where(C("Age").GT(18)),
+			wantQuery: &Query{
+				SQL:  "SELECT DISTINCT `first_name`,`last_name` AS `ln` FROM `test_model` WHERE `age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "distinct aggregate",
+			q:    NewSelector[TestModel](db).Select(Count("FirstName").Distinct().As("cnt"), Max("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT COUNT(DISTINCT `first_name`) AS `cnt`,MAX(`age`) FROM `test_model`;",
+			},
+		},
+		{
+			name: "distinct aggregate in having",
+			q: NewSelector[TestModel](db).Select(C("Age")).GroupBy(C("Age")).
+				Having(Count("FirstName").Distinct().EQ(2)),
+			wantQuery: &Query{
+				SQL:  "SELECT `age` FROM `test_model` GROUP BY `age` HAVING COUNT(DISTINCT `first_name`) = ?;",
+				Args: []any{2},
+			},
+		},
+		{
+			name: "both",
+			q: NewSelector[TestModel](db).Distinct().
+				Select(C("Age"), Count("Id").Distinct()).GroupBy(C("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT DISTINCT `age`,COUNT(DISTINCT `id`) FROM `test_model` GROUP BY `age`;",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Join(t *testing.T) {
+	type Order struct {
+		Id     int64
+		UserId int64
+		Amount int64
+	}
+	type User struct {
+		Id   int64
+		Name string
+	}
+	type OrderDetail struct {
+		OrderId int64
+		ItemId  int64
+	}
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "join",
+			q: func() QueryBuilder {
+				o := TableOf[Order]()
+				u := TableOf[User]()
+				return NewSelector[Order](db).From(o.Join(u).On(o.C("UserId").EQ(u.C("Id"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` JOIN `user` ON `order`.`user_id` = `user`.`id`;",
+			},
+		},
+		{
+			// 没有指定表的列在参与 JOIN 的表里面查找，并且加上表名作为前缀
+			name: "join without table column",
+			q: func() QueryBuilder {
+				u := TableOf[User]()
+				return NewSelector[Order](db).From(TableOf[Order]().Join(u).On(C("UserId").EQ(u.C("Id"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` JOIN `user` ON `order`.`user_id` = `user`.`id`;",
+			},
+		},
+		{
+			// 只有被 JOIN 的表才有的字段，使用被 JOIN 的表的模型
+			name: "column of joined table",
+			q: func() QueryBuilder {
+				o := TableOf[Order]().As("o")
+				u := TableOf[User]().As("u")
+				return NewSelector[Order](db).Select(C("Amount"), C("Name"), Count("Name").As("cnt")).
+					From(o.Join(u).On(o.C("UserId").EQ(u.C("Id")))).
+					Where(C("Name").EQ("Tom")).GroupBy(C("Amount"), C("Name"))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `o`.`amount`,`u`.`name`,COUNT(`u`.`name`) AS `cnt` FROM `order` AS `o` " +
+					"JOIN `user` AS `u` ON `o`.`user_id` = `u`.`id` WHERE `u`.`name` = ? GROUP BY `o`.`amount`,`u`.`name`;",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			// 两个表都有 Id，必须指定表
+			name: "ambiguous column",
+			q: func() QueryBuilder {
+				o := TableOf[Order]()
+				u := TableOf[User]()
+				return NewSelector[Order](db).Select(C("Id")).From(o.Join(u).On(o.C("UserId").EQ(u.C("Id"))))
+			}(),
+			wantErr: errs.NewErrAmbiguousColumn("Id"),
+		},
+		{
+			name: "ambiguous column in on",
+			q: func() QueryBuilder {
+				u := TableOf[User]()
+				return NewSelector[Order](db).From(TableOf[Order]().Join(u).On(C("UserId").EQ(C("Id"))))
+			}(),
+			wantErr: errs.NewErrAmbiguousColumn("Id"),
+		},
+		{
+			name: "unknown column in join",
+			q: func() QueryBuilder {
+				o := TableOf[Order]()
+				u := TableOf[User]()
+				return NewSelector[Order](db).Select(C("Invalid")).From(o.Join(u).On(o.C("UserId").EQ(u.C("Id"))))
+			}(),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "left join with alias",
+			q: func() QueryBuilder {
+				o := TableOf[Order]().As("o")
+				u := TableOf[User]().As("u")
+				return NewSelector[Order](db).
+					Select(o.C("Id"), u.C("Name").As("user_name")).
+					From(o.LeftJoin(u).On(o.C("UserId").EQ(u.C("Id")))).
+					Where(o.C("Amount").GT(100))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `o`.`id`,`u`.`name` AS `user_name` FROM `order` AS `o` " +
+					"LEFT JOIN `user` AS `u` ON `o`.`user_id` = `u`.`id` WHERE `o`.`amount` > ?;",
+				Args: []any{100},
+			},
+		},
+		{
+			name: "right join multiple on",
+			q: func() QueryBuilder {
+				o := TableOf[Order]().As("o")
+				u := TableOf[User]().As("u")
+				return NewSelector[Order](db).
+					From(o.RightJoin(u).On(o.C("UserId").EQ(u.C("Id")), u.C("Name").EQ("Tom")))
+			}(),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `order` AS `o` RIGHT JOIN `user` AS `u` ON `o`.`user_id` = `u`.`id` AND `u`.`name` = ?;",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			name: "join join",
+			q: func() QueryBuilder {
+				o := TableOf[Order]().As("o")
+				u := TableOf[User]().As("u")
+				od := TableOf[OrderDetail]().As("od")
+				return NewSelector[Order](db).
+					From(o.Join(u).On(o.C("UserId").EQ(u.C("Id"))).
+						LeftJoin(od).On(o.C("Id").EQ(od.C("OrderId"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` AS `o` JOIN `user` AS `u` ON `o`.`user_id` = `u`.`id` " +
+					"LEFT JOIN `order_detail` AS `od` ON `o`.`id` = `od`.`order_id`;",
+			},
+		},
+		{
+			name: "unknown field",
+			q: func() QueryBuilder {
+				o := TableOf[Order]()
+				u := TableOf[User]()
+				return NewSelector[Order](db).From(o.Join(u).On(o.C("UserId").EQ(u.C("UserId"))))
+			}(),
+			wantErr: errs.NewErrUnknownField("UserId"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Precedence(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+	}{
+		{
+			// AND 的优先级比 OR 高，所以不需要括号
+			name: "(a AND b) OR c",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").GT(18).And(C("Age").LT(30)).Or(C("FirstName").EQ("Tom"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? AND `age` < ? OR `first_name` = ?;",
+				Args: []any{18, 30, "Tom"},
+			},
+		},
+		{
+			name: "a OR (b AND c)",
+			q: NewSelector[TestModel](db).
+				Where(C("FirstName").EQ("Tom").Or(C("Age").GT(18).And(C("Age").LT(30)))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ? OR `age` > ? AND `age` < ?;",
+				Args: []any{"Tom", 18, 30},
+			},
+		},
+		{
+			name: "(a OR b) AND c",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").LT(18).Or(C("Age").GT(60)).And(C("FirstName").EQ("Tom"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`age` < ? OR `age` > ?) AND `first_name` = ?;",
+				Args: []any{18, 60, "Tom"},
+			},
+		},
+		{
+			name: "a AND (b OR c)",
+			q: NewSelector[TestModel](db).
+				Where(C("FirstName").EQ("Tom").And(C("Age").LT(18).Or(C("Age").GT(60)))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ? AND (`age` < ? OR `age` > ?);",
+				Args: []any{"Tom", 18, 60},
+			},
+		},
+		{
+			// 多个 Where 条件之间使用 AND 连接，所以 OR 需要括号
+			name: "multiple where with or",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").LT(18).Or(C("Age").GT(60)), C("FirstName").EQ("Tom")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`age` < ? OR `age` > ?) AND `first_name` = ?;",
+				Args: []any{18, 60, "Tom"},
+			},
+		},
+		{
+			name: "not and",
+			q: NewSelector[TestModel](db).
+				Where(Not(C("Age").GT(18).And(C("FirstName").EQ("Tom")))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE NOT (`age` > ? AND `first_name` = ?);",
+				Args: []any{18, "Tom"},
+			},
+		},
+		{
+			name: "or or",
+			q: NewSelector[TestModel](db).
+				Where(C("Id").EQ(1).Or(C("Id").EQ(2).Or(C("Id").EQ(3)))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` = ? OR `id` = ? OR `id` = ?;",
+				Args: []any{1, 2, 3},
+			},
+		},
+		{
+			name: "math expression",
+			q:    NewSelector[TestModel](db).Where(C("Age").GT(C("Id").Add(C("Age").Add(1)))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > `id` + `age` + ?;",
+				Args: []any{1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_MathExpr(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select",
+			q:    NewSelector[TestModel](db).Select(C("Id"), C("Age").Multi(2).As("double_age")),
+			wantQuery: &Query{
+				SQL:  "SELECT `id`,`age` * ? AS `double_age` FROM `test_model`;",
+				Args: []any{2},
+			},
+		},
+		{
+			name: "select without alias",
+			q:    NewSelector[TestModel](db).Select(C("Age").Sub(C("Id"))),
+			wantQuery: &Query{
+				SQL: "SELECT `age` - `id` FROM `test_model`;",
+			},
+		},
+		{
+			// 乘法的优先级更高，不需要括号
+			name: "multi then add",
+			q:    NewSelector[TestModel](db).Select(C("Age").Multi(2).Add(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT `age` * ? + ? FROM `test_model`;",
+				Args: []any{2, 1},
+			},
+		},
+		{
+			name: "add then multi",
+			q:    NewSelector[TestModel](db).Select(C("Age").Add(1).Multi(2)),
+			wantQuery: &Query{
+				SQL:  "SELECT (`age` + ?) * ? FROM `test_model`;",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			// 减法不满足结合律，右边的操作数要加括号
+			name: "sub nested",
+			q:    NewSelector[TestModel](db).Select(C("Age").Sub(C("Id").Sub(1))),
+			wantQuery: &Query{
+				SQL:  "SELECT `age` - (`id` - ?) FROM `test_model`;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "sub then sub",
+			q:    NewSelector[TestModel](db).Select(C("Age").Sub(C("Id")).Sub(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT `age` - `id` - ? FROM `test_model`;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "where",
+			q:    NewSelector[TestModel](db).Where(C("Age").GT(C("Id").Multi(2).Sub(1))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > `id` * ? - ?;",
+				Args: []any{2, 1},
+			},
+		},
+		{
+			name:    "unknown field",
+			q:       NewSelector[TestModel](db).Select(C("Invalid").Multi(2)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "unknown fields",
+			q:       NewSelector[TestModel](db).Select(C("Invalid").Multi(C("Age").Add(C("Other")))),
+			wantErr: errs.NewErrUnknownFields([]string{"Invalid", "Other"}),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Like(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "like",
+			q:    NewSelector[TestModel](db).Where(C("FirstName").Like("%Tom%")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` LIKE ?;",
+				Args: []any{"%Tom%"},
+			},
+		},
+		{
+			name: "not like",
+			q:    NewSelector[TestModel](db).Where(C("FirstName").NotLike("Tom%")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` NOT LIKE ?;",
+				Args: []any{"Tom%"},
+			},
+		},
+		{
+			name: "like and",
+			q: NewSelector[TestModel](db).
+				Where(C("FirstName").Like("%Tom%").And(C("Age").GT(18))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` LIKE ? AND `age` > ?;",
+				Args: []any{"%Tom%", 18},
+			},
+		},
+		{
+			name: "multiple where",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").GT(18), C("LastName").NotLike("%Jerry")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? AND `last_name` NOT LIKE ?;",
+				Args: []any{18, "%Jerry"},
+			},
+		},
+		{
+			name:    "unknown field",
+			q:       NewSelector[TestModel](db).Where(C("Name").Like("%Tom%")),
+			wantErr: errs.NewErrUnknownField("Name"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Compare(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name   string
+		op     string
+		column func(c Column, arg any) Predicate
+		agg    func(a Aggregate, arg any) Predicate
+	}{
+		{name: "eq", op: "=", column: Column.EQ, agg: Aggregate.EQ},
+		{name: "not eq", op: "!=", column: Column.NotEQ, agg: Aggregate.NotEQ},
+		{name: "lt", op: "<", column: Column.LT, agg: Aggregate.LT},
+		{name: "lt eq", op: "<=", column: Column.LTEQ, agg: Aggregate.LTEQ},
+		{name: "gt", op: ">", column: Column.GT, agg: Aggregate.GT},
+		{name: "gt eq", op: ">=", column: Column.GTEQ, agg: Aggregate.GTEQ},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 字面量作为参数传递
+			query, err := NewSelector[TestModel](db).Where(tc.column(C("Age"), 18)).Build()
+			require.NoError(t, err)
+			assert.Equal(t, &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` " + tc.op + " ?;",
+				Args: []any{18},
+			}, query)
+
+			// 列直接拼接进去
+			query, err = NewSelector[TestModel](db).Where(tc.column(C("Age"), C("Id"))).Build()
+			require.NoError(t, err)
+			assert.Equal(t, &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `age` " + tc.op + " `id`;",
+			}, query)
+
+			query, err = NewSelector[TestModel](db).GroupBy(C("FirstName")).
+				Having(tc.agg(Avg("Age"), 18)).Build()
+			require.NoError(t, err)
+			assert.Equal(t, &Query{
+				SQL:  "SELECT * FROM `test_model` GROUP BY `first_name` HAVING AVG(`age`) " + tc.op + " ?;",
+				Args: []any{18},
+			}, query)
+
+			query, err = NewSelector[TestModel](db).GroupBy(C("FirstName")).
+				Having(tc.agg(Avg("Age"), Min("Id"))).Build()
+			require.NoError(t, err)
+			assert.Equal(t, &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `first_name` HAVING AVG(`age`) " + tc.op + " MIN(`id`);",
+			}, query)
+
+			query, err = NewSelector[TestModel](db).GroupBy(C("FirstName")).
+				Having(tc.agg(Max("Age"), C("Id"))).Build()
+			require.NoError(t, err)
+			assert.Equal(t, &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `first_name` HAVING MAX(`age`) " + tc.op + " `id`;",
+			}, query)
+		})
+	}
+}
+
+func TestSelector_CompareSubquery(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "selector",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(
+				NewSelector[TestModel](db).Select(Avg("Age")).Where(C("FirstName").EQ("Tom")))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > (SELECT AVG(`age`) FROM `test_model` WHERE `first_name` = ?);",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			name: "aggregate with selector",
+			q: NewSelector[TestModel](db).GroupBy(C("FirstName")).Having(Avg("Age").GTEQ(
+				NewSelector[TestModel](db).Select(Avg("Age")))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `first_name` HAVING AVG(`age`) >= (SELECT AVG(`age`) FROM `test_model`);",
+			},
+		},
+		{
+			name:    "unknown column",
+			q:       NewSelector[TestModel](db).Where(C("Age").NotEQ(C("Invalid"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_BetweenAndNull(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "between",
+			q:    NewSelector[TestModel](db).Where(C("Age").Between(18, 30)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` BETWEEN ? AND ?;",
+				Args: []any{18, 30},
+			},
+		},
+		{
+			name: "between and",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").Between(18, 30).And(C("Id").GT(10))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` BETWEEN ? AND ? AND `id` > ?;",
+				Args: []any{18, 30, 10},
+			},
+		},
+		{
+			name: "is null",
+			q:    NewSelector[TestModel](db).Where(C("LastName").IsNull()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `last_name` IS NULL;",
+			},
+		},
+		{
+			name: "is not null",
+			q:    NewSelector[TestModel](db).Where(C("LastName").IsNotNull()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `last_name` IS NOT NULL;",
+			},
+		},
+		{
+			name: "is null or",
+			q: NewSelector[TestModel](db).
+				Where(C("LastName").IsNull().Or(C("LastName").EQ("Jerry"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `last_name` IS NULL OR `last_name` = ?;",
+				Args: []any{"Jerry"},
+			},
+		},
+		{
+			name: "nested is null",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").GT(18), C("LastName").IsNotNull().Or(C("FirstName").IsNull())),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? AND (`last_name` IS NOT NULL OR `first_name` IS NULL);",
+				Args: []any{18},
+			},
+		},
+		{
+			name:    "unknown field",
+			q:       NewSelector[TestModel](db).Where(C("DeletedAt").IsNull()),
+			wantErr: errs.NewErrUnknownField("DeletedAt"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_In(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "in",
+			q:    NewSelector[TestModel](db).Where(C("Id").In(1, 2, 3)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (?,?,?);",
+				Args: []any{1, 2, 3},
+			},
+		},
+		{
+			name: "not in",
+			q:    NewSelector[TestModel](db).Where(C("Id").NotIn(1, 2)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` NOT IN (?,?);",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name: "in with and",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").GT(18).And(C("FirstName").In("Tom", "Jerry"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? AND `first_name` IN (?,?);",
+				Args: []any{18, "Tom", "Jerry"},
+			},
+		},
+		{
+			name: "subquery",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(18), C("Id").In(
+				NewSelector[TestModel](db).Select(C("Id")).Where(C("FirstName").EQ("Tom")).AsSubquery("sub"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? AND `id` IN (SELECT `id` FROM `test_model` WHERE `first_name` = ?);",
+				Args: []any{18, "Tom"},
+			},
+		},
+		{
+			// 原生查询可能没有分号，不能去掉最后一个字符
+			name: "raw query without semicolon",
+			q: NewSelector[TestModel](db).Where(C("Id").In(
+				RawQuery[TestModel](db, "SELECT `id` FROM `test_model` WHERE `age` = 1"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `id` IN (SELECT `id` FROM `test_model` WHERE `age` = 1);",
+			},
+		},
+		{
+			name: "raw query with semicolon",
+			q: NewSelector[TestModel](db).Where(C("Id").In(
+				RawQuery[TestModel](db, "SELECT `id` FROM `test_model` WHERE `age` = ?;", 18))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (SELECT `id` FROM `test_model` WHERE `age` = ?);",
+				Args: []any{18},
+			},
+		},
+		{
+			// 直接传入 Selector
+			name: "selector",
+			q: NewSelector[TestModel](db).Where(C("Id").NotIn(
+				NewSelector[TestModel](db).Select(C("Id")).Where(C("Age").LT(18)))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` NOT IN (SELECT `id` FROM `test_model` WHERE `age` < ?);",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "empty in",
+			q:    NewSelector[TestModel](db).Where(C("Id").In()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE 1 = 0;",
+			},
+		},
+		{
+			name: "empty not in",
+			q:    NewSelector[TestModel](db).Where(C("Age").GT(18), C("Id").NotIn()),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? AND (1 = 1);",
+				Args: []any{18},
+			},
+		},
+		{
+			// 切片需要展开
+			name: "slice",
+			q:    NewSelector[TestModel](db).Where(C("Id").In([]any{1, 2}...)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (?,?);",
+				Args: []any{1, 2},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+
+	// 空的原生查询不会因为去掉分号而 panic
+	assert.NotPanics(t, func() {
+		_, _ = NewSelector[TestModel](db).Where(C("Id").In(RawQuery[TestModel](db, ""))).Build()
+	})
+}
+
+func TestSelector_WhereRaw(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+	}{
+		{
+			name: "only raw",
+			q:    NewSelector[TestModel](db).WhereRaw("age > ?", 18),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE age > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "with predicate",
+			q: NewSelector[TestModel](db).Where(C("FirstName").EQ("Tom")).
+				WhereRaw("age > ?", 18),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ? AND (age > ?);",
+				Args: []any{"Tom", 18},
+			},
+		},
+		{
+			name: "multiple raw",
+			q: NewSelector[TestModel](db).WhereRaw("age > ?", 18).
+				WhereRaw("age < ? OR first_name = ?", 60, "Tom"),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (age > ?) AND (age < ? OR first_name = ?);",
+				Args: []any{18, 60, "Tom"},
+			},
+		},
+		{
+			// 原生表达式的参数插在前后两个条件的参数中间
+			name: "raw predicate with and",
+			q: NewSelector[TestModel](db).
+				Where(C("FirstName").EQ("Tom").And(Raw("`age` < ?", 18).AsPredicate()).And(C("Id").GT(3))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ? AND (`age` < ?) AND `id` > ?;",
+				Args: []any{"Tom", 18, 3},
+			},
+		},
+		{
+			name: "raw predicate in where",
+			q: NewSelector[TestModel](db).
+				Where(Raw("`age` < ?", 18).AsPredicate(), C("Id").GT(3)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`age` < ?) AND `id` > ?;",
+				Args: []any{18, 3},
+			},
+		},
+		{
+			// 作为操作数使用，例如数据库特有的函数
+			name: "raw operand",
+			q: NewSelector[TestModel](db).
+				Where(C("Id").GT(1), C("FirstName").EQ(Raw("CONCAT(?, `last_name`)", "Tom")), C("Age").LT(60)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` > ? AND `first_name` = CONCAT(?, `last_name`) AND `age` < ?;",
+				Args: []any{1, "Tom", 60},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+// TestSelector_ArgsOrder 确保参数的顺序和 SQL 里面占位符的顺序一致，
+// 这样复用 prepared statement 的时候参数的位置是确定的
+func TestSelector_ArgsOrder(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+	}{
+		{
+			name: "nested predicates",
+			q: NewSelector[TestModel](db).
+				Select(C("Id"), CountIf(C("Age").GT(1)).As("cnt")).
+				Where(C("Age").GT(2).And(Raw("first_name = ? OR last_name = ?", 3, 4).AsPredicate().Or(Not(C("Id").EQ(5)))),
+					C("Id").LT(6).Or(C("Id").EQ(NewSelector[TestModel](db).Select(Max("Id")).
+						Where(C("Age").LT(7)).AsSubquery("sub")).And(C("FirstName").EQ(8)))).
+				WhereRaw("age <> ?", 9).
+				GroupBy(C("FirstName")).
+				Having(Count("Id").EQ(10)).
+				Limit(11).Offset(12),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,SUM(CASE WHEN `age` > ? THEN 1 ELSE 0 END) AS `cnt` FROM `test_model` " +
+					"WHERE `age` > ? AND ((first_name = ? OR last_name = ?) OR NOT `id` = ?) " +
+					"AND (`id` < ? OR `id` = (SELECT MAX(`id`) FROM `test_model` WHERE `age` < ?) AND `first_name` = ?) " +
+					"AND (age <> ?) GROUP BY `first_name` HAVING COUNT(`id`) = ? LIMIT ? OFFSET ?;",
+				Args: []any{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+			},
+		},
+		{
+			name: "union",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(1)).
+				Union(NewSelector[TestModel](db).Where(C("Age").LT(2).Or(Raw("id = ?", 3).AsPredicate()))).
+				Limit(4),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? UNION SELECT * FROM `test_model` WHERE `age` < ? OR (id = ?) LIMIT ?;",
+				Args: []any{1, 2, 3, 4},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, query)
+			assert.Equal(t, strings.Count(query.SQL, "?"), len(query.Args))
+			// 重复构造的结果保持一致
+			query, err = tc.q.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Having(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			// 调用了，但是啥也没传
+			name: "none",
+			q:    NewSelector[TestModel](db).GroupBy(C("Age")).Having(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `age`;",
+			},
+		},
+		{
+			// 单个条件
+			name: "single",
+			q: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(C("FirstName").EQ("Deng")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` GROUP BY `age` HAVING `first_name` = ?;",
+				Args: []any{"Deng"},
+			},
+		},
+		{
+			// 多个条件
+			name: "multiple",
+			q: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(C("FirstName").EQ("Deng"), C("LastName").EQ("Ming")),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` GROUP BY `age` HAVING `first_name` = ? AND `last_name` = ?;",
+				Args: []any{"Deng", "Ming"},
+			},
+		},
+		{
+			// 聚合函数
+			name: "avg",
+			q: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(Avg("Age").EQ(18)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` GROUP BY `age` HAVING AVG(`age`) = ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "sum",
+			q: NewSelector[TestModel](db).Select(C("FirstName"), Sum("Age")).GroupBy(C("FirstName")).
+				Having(Sum("Age").GT(100), Count("Id").LT(10)),
+			wantQuery: &Query{
+				SQL:  "SELECT `first_name`,SUM(`age`) FROM `test_model` GROUP BY `first_name` HAVING SUM(`age`) > ? AND COUNT(`id`) < ?;",
+				Args: []any{100, 10},
+			},
+		},
+		{
+			// 聚合函数和列比较
+			name: "aggregate and column",
+			q: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(Max("Id").GT(C("Age"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `age` HAVING MAX(`id`) > `age`;",
+			},
+		},
+		{
+			name: "invalid aggregate",
+			q: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(Sum("Invalid").GT(100)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_GroupBy(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			// 调用了，但是啥也没传
+			name: "none",
+			q:    NewSelector[TestModel](db).GroupBy(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model`;",
+			},
+		},
+		{
+			// 单个
+			name: "single",
+			q:    NewSelector[TestModel](db).GroupBy(C("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `age`;",
+			},
+		},
+		{
+			// 多个
+			name: "multiple",
+			q:    NewSelector[TestModel](db).GroupBy(C("Age"), C("FirstName")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `age`,`first_name`;",
+			},
+		},
+		{
+			// 不存在
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).GroupBy(C("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_StrictGroupBy(t *testing.T) {
+	db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithStrictGroupBy())
+	require.NoError(t, err)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "grouped and aggregated",
+			q: NewSelector[TestModel](db).Select(C("Age"), Count("Id").As("cnt")).
+				GroupBy(C("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT `age`,COUNT(`id`) AS `cnt` FROM `test_model` GROUP BY `age`;",
+			},
+		},
+		{
+			// 比较的时候忽略列的别名
+			name: "grouped with alias",
+			q: NewSelector[TestModel](db).Select(C("Age").As("my_age"), Max("Id")).
+				GroupBy(C("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT `age` AS `my_age`,MAX(`id`) FROM `test_model` GROUP BY `age`;",
+			},
+		},
+		{
+			name: "math expr",
+			q: NewSelector[TestModel](db).Select(C("Age").Add(1).As("next_age")).
+				GroupBy(C("Age")),
+			wantQuery: &Query{
+				SQL:  "SELECT `age` + ? AS `next_age` FROM `test_model` GROUP BY `age`;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "ungrouped column",
+			q: NewSelector[TestModel](db).Select(C("Age"), C("FirstName")).
+				GroupBy(C("Age")),
+			wantErr: errs.NewErrUngroupedColumn("FirstName"),
+		},
+		{
+			name: "ungrouped column in math expr",
+			q: NewSelector[TestModel](db).Select(C("Age").Add(C("Id"))).
+				GroupBy(C("Age")),
+			wantErr: errs.NewErrUngroupedColumn("Id"),
+		},
+		{
+			// 没有 GROUP BY 的时候不校验
+			name: "no group by",
+			q:    NewSelector[TestModel](db).Select(C("Age"), C("FirstName")),
+			wantQuery: &Query{
+				SQL: "SELECT `age`,`first_name` FROM `test_model`;",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+
+	// 默认不校验
+	_, err = NewSelector[TestModel](memoryDB(t)).Select(C("Age"), C("FirstName")).
+		GroupBy(C("Age")).Build()
+	assert.NoError(t, err)
+}
+
+func TestSelector_Select(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			// 没有指定
+			name: "all",
+			q:    NewSelector[TestModel](db),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model`;",
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).Select(Avg("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "partial columns",
+			q:    NewSelector[TestModel](db).Select(C("Id"), C("FirstName")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,`first_name` FROM `test_model`;",
+			},
+		},
+		{
+			name: "avg",
+			q:    NewSelector[TestModel](db).Select(Avg("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT AVG(`age`) FROM `test_model`;",
+			},
+		},
+		{
+			name: "raw expression",
+			q:    NewSelector[TestModel](db).Select(Raw("COUNT(DISTINCT `first_name`)")),
+			wantQuery: &Query{
+				SQL: "SELECT COUNT(DISTINCT `first_name`) FROM `test_model`;",
+			},
+		},
+		// 别名
+		{
+			name: "alias",
+			q: NewSelector[TestModel](db).
+				Select(C("Id").As("my_id"),
+					Avg("Age").As("avg_age")),
+			wantQuery: &Query{
+				SQL: "SELECT `id` AS `my_id`,AVG(`age`) AS `avg_age` FROM `test_model`;",
+			},
+		},
+		// WHERE 忽略别名
+		{
+			name: "where ignore alias",
+			q: NewSelector[TestModel](db).
+				Where(C("Id").As("my_id").LT(100)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` < ?;",
+				Args: []any{100},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_SelectExcept(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "except one",
+			q:    NewSelector[TestModel](db).SelectExcept("Age"),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,`first_name`,`last_name` FROM `test_model`;",
+			},
+		},
+		{
+			name: "except multiple",
+			q:    NewSelector[TestModel](db).SelectExcept("FirstName", "LastName"),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,`age` FROM `test_model`;",
+			},
+		},
+		{
+			// 没有排除任何字段，也是列出全部的列，而不是 *
+			name: "except none",
+			q:    NewSelector[TestModel](db).SelectExcept(),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,`first_name`,`age`,`last_name` FROM `test_model`;",
+			},
+		},
+		{
+			name: "with where",
+			q: NewSelector[TestModel](db).SelectExcept("Age").
+				Where(C("Age").GT(18)),
+			wantQuery: &Query{
+				SQL:  "SELECT `id`,`first_name`,`last_name` FROM `test_model` WHERE `age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			// 后调用的 Select 覆盖 SelectExcept
+			name: "override by select",
+			q:    NewSelector[TestModel](db).SelectExcept("Age").Select(C("Id")),
+			wantQuery: &Query{
+				SQL: "SELECT `id` FROM `test_model`;",
+			},
+		},
+		{
+			// 需要的是字段名，而不是列名
+			name:    "unknown field",
+			q:       NewSelector[TestModel](db).SelectExcept("first_name"),
+			wantErr: errs.NewErrUnknownField("first_name"),
+		},
+		{
+			name:    "unknown fields",
+			q:       NewSelector[TestModel](db).SelectExcept("Invalid", "Age", "Password"),
+			wantErr: errs.NewErrUnknownFields([]string{"Invalid", "Password"}),
+		},
+		{
+			name:    "except all",
+			q:       NewSelector[TestModel](db).SelectExcept("Id", "FirstName", "Age", "LastName"),
+			wantErr: errs.ErrNoSelectedColumns,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			// From 都不调用
+			name: "no from",
+			q:    NewSelector[TestModel](db),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model`;",
+			},
+		},
+		{
+			// 调用 FROM
+			name: "with from",
+			q:    NewSelector[TestModel](db).From(Raw("`test_model_t`")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model_t`;",
+			},
+		},
+		{
+			// 调用 FROM，但是传入 nil
+			name: "empty from",
+			q:    NewSelector[TestModel](db).From(nil),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model`;",
+			},
+		},
+		{
+			// 调用 FROM，同时出入看了 DB
+			name: "with db",
+			q:    NewSelector[TestModel](db).From(Raw("`test_db`.`test_model`")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_db`.`test_model`;",
+			},
+		},
+		{
+			// 单一简单条件
+			name: "single and simple predicate",
+			q: NewSelector[TestModel](db).From(Raw("`test_model_t`")).
+				Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model_t` WHERE `id` = ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			// 多个 predicate
+			name: "multiple predicates",
+			q: NewSelector[TestModel](db).
 				Where(C("Age").GT(18), C("Age").LT(35)),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE (`age` > ?) AND (`age` < ?);",
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? AND `age` < ?;",
 				Args: []any{18, 35},
 			},
 		},
@@ -350,7 +2452,7 @@ func TestSelector_Build(t *testing.T) {
 			q: NewSelector[TestModel](db).
 				Where(C("Age").GT(18).And(C("Age").LT(35))),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE (`age` > ?) AND (`age` < ?);",
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? AND `age` < ?;",
 				Args: []any{18, 35},
 			},
 		},
@@ -360,7 +2462,7 @@ func TestSelector_Build(t *testing.T) {
 			q: NewSelector[TestModel](db).
 				Where(C("Age").GT(18).Or(C("Age").LT(35))),
 			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE (`age` > ?) OR (`age` < ?);",
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > ? OR `age` < ?;",
 				Args: []any{18, 35},
 			},
 		},
@@ -370,41 +2472,317 @@ func TestSelector_Build(t *testing.T) {
 			q:    NewSelector[TestModel](db).Where(Not(C("Age").GT(18))),
 			wantQuery: &Query{
 				// NOT 前面有两个空格，因为我们没有对 NOT 进行特殊处理
-				SQL:  "SELECT * FROM `test_model` WHERE  NOT (`age` > ?);",
+				SQL:  "SELECT * FROM `test_model` WHERE NOT `age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			// 非法列
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).Where(Not(C("Invalid").GT(18))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			// 使用 RawExpr
+			name: "raw expression",
+			q: NewSelector[TestModel](db).
+				Where(Raw("`age` < ?", 18).AsPredicate()),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` < ?;",
 				Args: []any{18},
 			},
 		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Subquery(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			// 标量子查询
+			name: "scalar",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").GT(NewSelector[TestModel](db).
+					Select(Avg("Age")).AsSubquery("sub"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `age` > (SELECT AVG(`age`) FROM `test_model`);",
+			},
+		},
+		{
+			// 子查询的参数要按照出现的顺序合并
+			name: "merge args",
+			q: NewSelector[TestModel](db).
+				Where(C("FirstName").EQ("Tom"),
+					C("Age").GT(NewSelector[TestModel](db).
+						Select(Avg("Age")).Where(C("Id").LT(100)).AsSubquery("sub")),
+					C("Id").GT(10)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` = ? AND `age` > (SELECT AVG(`age`) FROM `test_model` WHERE `id` < ?) AND `id` > ?;",
+				Args: []any{"Tom", 100, 10},
+			},
+		},
+		{
+			// 子查询构造失败
+			name: "invalid subquery",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").GT(NewSelector[TestModel](db).
+					Select(Avg("Invalid")).AsSubquery("sub"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_FromSubquery(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select all",
+			q:    NewSelector[TestModel](db).From(NewSelector[TestModel](db).AsSubquery("t")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (SELECT * FROM `test_model`) AS `t`;",
+			},
+		},
+		{
+			// 外层查询使用子查询产生的列，参数按照出现的顺序合并
+			name: "filter on subquery column",
+			q: NewSelector[TestModel](db).Select(C("Age"), C("cnt")).
+				From(NewSelector[TestModel](db).Select(C("Age"), Count("Id").As("cnt")).
+					Where(C("FirstName").EQ("Tom")).GroupBy(C("Age")).AsSubquery("t")).
+				Where(C("cnt").GT(2)).OrderBy(Desc(C("cnt"))),
+			wantQuery: &Query{
+				SQL: "SELECT `age`,`cnt` FROM (SELECT `age`,COUNT(`id`) AS `cnt` FROM `test_model` " +
+					"WHERE `first_name` = ? GROUP BY `age`) AS `t` WHERE `cnt` > ? ORDER BY `cnt` DESC;",
+				Args: []any{"Tom", 2},
+			},
+		},
+		{
+			// 使用子查询的别名作为前缀
+			name: "subquery column",
+			q: func() QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("Id"), C("FirstName").As("name")).AsSubquery("t")
+				return NewSelector[TestModel](db).Select(sub.C("Id")).From(sub).
+					Where(sub.C("name").EQ("Tom"))
+			}(),
+			wantQuery: &Query{
+				SQL:  "SELECT `t`.`id` FROM (SELECT `id`,`first_name` AS `name` FROM `test_model`) AS `t` WHERE `t`.`name` = ?;",
+				Args: []any{"Tom"},
+			},
+		},
+		{
+			name: "as",
+			q: NewSelector[TestModel](db).
+				From(NewSelector[TestModel](db).Select(Max("Age").As("max_age")).AsSubquery("").As("t")).
+				Select(Max("max_age")),
+			wantQuery: &Query{
+				SQL: "SELECT MAX(`max_age`) FROM (SELECT MAX(`age`) AS `max_age` FROM `test_model`) AS `t`;",
+			},
+		},
+		{
+			name: "join subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("Id"), Count("Age").As("cnt")).
+					GroupBy(C("Id")).AsSubquery("t")
+				tm := TableOf[TestModel]().As("m")
+				return NewSelector[TestModel](db).Select(tm.C("FirstName"), sub.C("cnt")).
+					From(tm.Join(sub).On(tm.C("Id").EQ(sub.C("Id"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `m`.`first_name`,`t`.`cnt` FROM `test_model` AS `m` JOIN " +
+					"(SELECT `id`,COUNT(`age`) AS `cnt` FROM `test_model` GROUP BY `id`) AS `t` ON `m`.`id` = `t`.`id`;",
+			},
+		},
+		{
+			// 子查询没有选择这个列
+			name: "column not in subquery",
+			q: NewSelector[TestModel](db).
+				From(NewSelector[TestModel](db).Select(C("Age")).AsSubquery("t")).
+				Where(C("FirstName").EQ("Tom")),
+			wantErr: errs.NewErrUnknownField("FirstName"),
+		},
+		{
+			// 使用了别名之后只能通过别名引用
+			name: "column aliased in subquery",
+			q: NewSelector[TestModel](db).
+				From(NewSelector[TestModel](db).Select(C("Age").As("a")).AsSubquery("t")).
+				Select(C("Age")),
+			wantErr: errs.NewErrUnknownField("Age"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_Get(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		query    string
+		mockErr  error
+		mockRows *sqlmock.Rows
+		wantErr  error
+		wantVal  *TestModel
+	}{
+		{
+			// 查询返回错误
+			name:    "query error",
+			mockErr: errors.New("invalid query"),
+			wantErr: errs.NewErrQuery("SELECT", "test_model", "SELECT * FROM `test_model`;", errors.New("invalid query")),
+			query:   "SELECT .*",
+		},
+		{
+			name:     "no row",
+			wantErr:  ErrNoRows,
+			query:    "SELECT .*",
+			mockRows: sqlmock.NewRows([]string{"id"}),
+		},
 		{
-			// 非法列
-			name:    "invalid column",
-			q:       NewSelector[TestModel](db).Where(Not(C("Invalid").GT(18))),
-			wantErr: errs.NewErrUnknownField("Invalid"),
+			name:    "too many column",
+			wantErr: errs.ErrTooManyReturnedColumns,
+			query:   "SELECT .*",
+			mockRows: func() *sqlmock.Rows {
+				res := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name", "extra_column"})
+				res.AddRow([]byte("1"), []byte("Da"), []byte("18"), []byte("Ming"), []byte("nothing"))
+				return res
+			}(),
 		},
 		{
-			// 使用 RawExpr
-			name: "raw expression",
-			q: NewSelector[TestModel](db).
-				Where(Raw("`age` < ?", 18).AsPredicate()),
-			wantQuery: &Query{
-				SQL:  "SELECT * FROM `test_model` WHERE `age` < ?;",
-				Args: []any{18},
+			name:  "get data",
+			query: "SELECT .*",
+			mockRows: func() *sqlmock.Rows {
+				res := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+				res.AddRow([]byte("1"), []byte("Da"), []byte("18"), []byte("Ming"))
+				return res
+			}(),
+			wantVal: &TestModel{
+				Id:        1,
+				FirstName: "Da",
+				Age:       18,
+				LastName:  &sql.NullString{String: "Ming", Valid: true},
 			},
 		},
 	}
 
+	for _, tc := range testCases {
+		exp := mock.ExpectQuery(tc.query)
+		if tc.mockErr != nil {
+			exp.WillReturnError(tc.mockErr)
+		} else {
+			exp.WillReturnRows(tc.mockRows)
+		}
+	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			query, err := tc.q.Build()
+			res, err := NewSelector[TestModel](db).Get(context.Background())
 			assert.Equal(t, tc.wantErr, err)
 			if err != nil {
 				return
 			}
-			assert.Equal(t, tc.wantQuery, query)
+			assert.Equal(t, tc.wantVal, res)
 		})
 	}
 }
 
-func TestSelector_Get(t *testing.T) {
+func TestSelector_QueryError(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").WillReturnError(sql.ErrConnDone)
+	mock.ExpectQuery("SELECT .*").WillReturnError(sql.ErrConnDone)
+	mock.ExpectQuery("SELECT .*").WillReturnError(sql.ErrConnDone)
+
+	testCases := []struct {
+		name  string
+		query func() error
+	}{
+		{
+			name: "get",
+			query: func() error {
+				_, err := NewSelector[TestModel](db).Where(C("FirstName").EQ("secret")).Get(context.Background())
+				return err
+			},
+		},
+		{
+			name: "get multi",
+			query: func() error {
+				_, err := NewSelector[TestModel](db).Where(C("FirstName").EQ("secret")).GetMulti(context.Background())
+				return err
+			},
+		},
+		{
+			name: "iter",
+			query: func() error {
+				_, err := NewSelector[TestModel](db).Where(C("FirstName").EQ("secret")).Iter(context.Background())
+				return err
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.query()
+			// 依旧可以判断驱动返回的错误
+			assert.True(t, errors.Is(err, sql.ErrConnDone))
+			assert.Contains(t, err.Error(), "SELECT test_model")
+			assert.Contains(t, err.Error(), "SELECT * FROM `test_model` WHERE `first_name` = ?;")
+			// 参数不会出现在错误信息里面
+			assert.NotContains(t, err.Error(), "secret")
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_GetMulti(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatal(err)
@@ -421,20 +2799,20 @@ func TestSelector_Get(t *testing.T) {
 		mockErr  error
 		mockRows *sqlmock.Rows
 		wantErr  error
-		wantVal  *TestModel
+		wantVal  []*TestModel
 	}{
 		{
-			// 查询返回错误
 			name:    "query error",
 			mockErr: errors.New("invalid query"),
-			wantErr: errors.New("invalid query"),
+			wantErr: errs.NewErrQuery("SELECT", "test_model", "SELECT * FROM `test_model`;", errors.New("invalid query")),
 			query:   "SELECT .*",
 		},
 		{
+			// 没有数据的时候返回空切片，而不是 ErrNoRows
 			name:     "no row",
-			wantErr:  ErrNoRows,
 			query:    "SELECT .*",
 			mockRows: sqlmock.NewRows([]string{"id"}),
+			wantVal:  []*TestModel{},
 		},
 		{
 			name:    "too many column",
@@ -446,19 +2824,39 @@ func TestSelector_Get(t *testing.T) {
 				return res
 			}(),
 		},
+		{
+			name:    "row error",
+			wantErr: errs.NewErrQuery("SELECT", "test_model", "SELECT * FROM `test_model`;", errors.New("row error")),
+			query:   "SELECT .*",
+			mockRows: func() *sqlmock.Rows {
+				res := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
+				res.AddRow([]byte("1"), []byte("Da"), []byte("18"), []byte("Ming"))
+				res.AddRow([]byte("2"), []byte("Xiao"), []byte("20"), []byte("Hong"))
+				res.RowError(1, errors.New("row error"))
+				return res
+			}(),
+		},
 		{
 			name:  "get data",
 			query: "SELECT .*",
 			mockRows: func() *sqlmock.Rows {
 				res := sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"})
 				res.AddRow([]byte("1"), []byte("Da"), []byte("18"), []byte("Ming"))
+				res.AddRow([]byte("2"), []byte("Xiao"), []byte("20"), nil)
 				return res
 			}(),
-			wantVal: &TestModel{
-				Id:        1,
-				FirstName: "Da",
-				Age:       18,
-				LastName:  &sql.NullString{String: "Ming", Valid: true},
+			wantVal: []*TestModel{
+				{
+					Id:        1,
+					FirstName: "Da",
+					Age:       18,
+					LastName:  &sql.NullString{String: "Ming", Valid: true},
+				},
+				{
+					Id:        2,
+					FirstName: "Xiao",
+					Age:       20,
+				},
 			},
 		},
 	}
@@ -468,13 +2866,81 @@ func TestSelector_Get(t *testing.T) {
 		if tc.mockErr != nil {
 			exp.WillReturnError(tc.mockErr)
 		} else {
-			exp.WillReturnRows(tc.mockRows)
+			// 结果集必须被关闭
+			exp.WillReturnRows(tc.mockRows).RowsWillBeClosed()
 		}
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := NewSelector[TestModel](db).Get(context.Background())
+			res, err := NewSelector[TestModel](db).GetMulti(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantVal, res)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_GetMultiMap(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		mockErr  error
+		mockRows *sqlmock.Rows
+		wantErr  error
+		wantVal  []map[string]any
+	}{
+		{
+			name:    "query error",
+			mockErr: errors.New("invalid query"),
+			wantErr: errs.NewErrQuery("SELECT", "test_model",
+				"SELECT `first_name`,AVG(`age`) AS `avg_age` FROM `test_model` GROUP BY `first_name`;",
+				errors.New("invalid query")),
+		},
+		{
+			name:     "no row",
+			mockRows: sqlmock.NewRows([]string{"first_name", "avg_age"}),
+			wantVal:  []map[string]any{},
+		},
+		{
+			name: "with null",
+			mockRows: sqlmock.NewRows([]string{"first_name", "avg_age"}).
+				AddRow([]byte("Tom"), 18.5).
+				AddRow(nil, 20.0),
+			wantVal: []map[string]any{
+				{"first_name": []byte("Tom"), "avg_age": 18.5},
+				{"first_name": nil, "avg_age": 20.0},
+			},
+		},
+		{
+			name: "row error",
+			mockRows: sqlmock.NewRows([]string{"first_name", "avg_age"}).
+				AddRow([]byte("Tom"), 18.5).
+				RowError(0, errors.New("row error")),
+			wantErr: errs.NewErrQuery("SELECT", "test_model",
+				"SELECT `first_name`,AVG(`age`) AS `avg_age` FROM `test_model` GROUP BY `first_name`;",
+				errors.New("row error")),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exp := mock.ExpectQuery("SELECT .*")
+			if tc.mockErr != nil {
+				exp.WillReturnError(tc.mockErr)
+			} else {
+				exp.WillReturnRows(tc.mockRows)
+			}
+			res, err := NewSelector[TestModel](db).
+				Select(C("FirstName"), Avg("Age").As("avg_age")).
+				GroupBy(C("FirstName")).GetMultiMap(context.Background())
 			assert.Equal(t, tc.wantErr, err)
 			if err != nil {
 				return
@@ -482,6 +2948,305 @@ func TestSelector_Get(t *testing.T) {
 			assert.Equal(t, tc.wantVal, res)
 		})
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAs(t *testing.T) {
+	type AgeStats struct {
+		Cnt    int64
+		MaxAge int8
+	}
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(`id`) AS `cnt`,MAX(`age`) AS `max_age` FROM `test_model` WHERE `age` > ?;")).
+		WithArgs(18).
+		WillReturnRows(sqlmock.NewRows([]string{"cnt", "max_age"}).AddRow([]byte("3"), []byte("40")))
+
+	res, err := GetAs[AgeStats](context.Background(), NewSelector[TestModel](db).
+		Select(Count("Id").As("cnt"), Max("Age").As("max_age")).
+		Where(C("Age").GT(18)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &AgeStats{Cnt: 3, MaxAge: 40}, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMultiAs(t *testing.T) {
+	type NameStats struct {
+		FirstName string
+		Cnt       int64
+		AvgAge    float64
+	}
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `first_name`,COUNT(`id`) AS `cnt`,AVG(`age`) AS `avg_age` FROM `test_model` GROUP BY `first_name`;")).
+		WillReturnRows(sqlmock.NewRows([]string{"first_name", "cnt", "avg_age"}).
+			AddRow([]byte("Tom"), []byte("2"), []byte("19.5")).
+			AddRow([]byte("Jerry"), []byte("1"), []byte("30")))
+
+	res, err := GetMultiAs[NameStats](context.Background(), NewSelector[TestModel](db).
+		Select(C("FirstName"), Count("Id").As("cnt"), Avg("Age").As("avg_age")).
+		GroupBy(C("FirstName")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*NameStats{
+		{FirstName: "Tom", Cnt: 2, AvgAge: 19.5},
+		{FirstName: "Jerry", Cnt: 1, AvgAge: 30},
+	}, res)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// 构造失败的时候不会发起查询
+	_, err = GetMultiAs[NameStats](context.Background(), NewSelector[TestModel](db).Select(C("Invalid")))
+	assert.Equal(t, errs.NewErrUnknownField("Invalid"), err)
+}
+
+func TestSelector_Paginate(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// COUNT 查询去掉了 ORDER BY, LIMIT 和 OFFSET
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `test_model` WHERE `age` > ?;")).
+		WithArgs(18).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_model` WHERE `age` > ? ORDER BY `id` ASC LIMIT ? OFFSET ?;")).
+		WithArgs(18, 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "age", "last_name"}).
+			AddRow([]byte("3"), []byte("Tom"), []byte("20"), []byte("Jerry")))
+
+	rows, total, err := NewSelector[TestModel](db).
+		Where(C("Age").GT(18)).OrderBy(Asc(C("Id"))).
+		Paginate(context.Background(), 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []*TestModel{
+		{
+			Id:        3,
+			FirstName: "Tom",
+			Age:       20,
+			LastName:  &sql.NullString{String: "Jerry", Valid: true},
+		},
+	}, rows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, _, err = NewSelector[TestModel](db).Paginate(context.Background(), 0, 10)
+	assert.Equal(t, errs.NewErrInvalidPagination(0, 10), err)
+}
+
+func TestSelector_PaginateTwice(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	for _, offset := range []int{0, 2} {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `test_model` WHERE `age` > ?;")).
+			WithArgs(18).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(3))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_model` WHERE `age` > ? LIMIT ? OFFSET ?;")).
+			WithArgs(18, 2, offset).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow([]byte("1")))
+	}
+
+	// 同一个 Selector 查询不同的页，每一次的 LIMIT 和 OFFSET 都是独立的
+	s := NewSelector[TestModel](db).Where(C("Age").GT(18))
+	_, _, err = s.Paginate(context.Background(), 1, 2)
+	require.NoError(t, err)
+	_, _, err = s.Paginate(context.Background(), 2, 2)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// 分页查询不会给调用者的 Selector 加上 LIMIT 和 OFFSET
+	q, err := s.Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  "SELECT * FROM `test_model` WHERE `age` > ?;",
+		Args: []any{18},
+	}, q)
+}
+
+func TestSelector_Page(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	// GROUP BY 的时候统计的是分组的数量，page 小于 1 当作第一页
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT `age` FROM `test_model` WHERE `id` > ? GROUP BY `age`) AS `t`;")).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(5))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `age` FROM `test_model` WHERE `id` > ? GROUP BY `age` LIMIT ? OFFSET ?;")).
+		WithArgs(10, 2, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"age"}).AddRow([]byte("18")).AddRow([]byte("20")))
+
+	rows, total, err := NewSelector[TestModel](db).Select(C("Age")).
+		Where(C("Id").GT(10)).GroupBy(C("Age")).
+		Page(context.Background(), 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+	assert.Equal(t, []*TestModel{{Age: 18}, {Age: 20}}, rows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, _, err = NewSelector[TestModel](db).Page(context.Background(), 1, 0)
+	assert.Equal(t, errs.NewErrInvalidPagination(1, 0), err)
+}
+
+func TestSelector_PageSameAsPaginate(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	require.NoError(t, err)
+
+	// Page 和 Paginate 发起的查询完全一样
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `test_model` WHERE `age` > ?;")).
+			WithArgs(18).
+			WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(7))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `test_model` WHERE `age` > ? LIMIT ? OFFSET ?;")).
+			WithArgs(18, 5, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow([]byte("6")))
+	}
+	s := NewSelector[TestModel](db).Where(C("Age").GT(18))
+	rows, total, err := s.Paginate(context.Background(), 2, 5)
+	require.NoError(t, err)
+	pageRows, pageTotal, err := s.Page(context.Background(), 2, 5)
+	require.NoError(t, err)
+	assert.Equal(t, rows, pageRows)
+	assert.Equal(t, int(total), pageTotal)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelector_buildCount(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		s         *Selector[TestModel]
+		wantQuery *Query
+	}{
+		{
+			name: "where",
+			s: NewSelector[TestModel](db).Select(C("FirstName")).
+				Where(C("Age").GT(18)).OrderBy(Desc(C("Age"))).Limit(10).Offset(20),
+			wantQuery: &Query{
+				SQL:  "SELECT COUNT(*) FROM `test_model` WHERE `age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			// 分组的时候统计的是分组的数量
+			name: "group by",
+			s: NewSelector[TestModel](db).Where(C("Age").GT(18)).
+				GroupBy(C("Age")).Having(Count("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT COUNT(*) FROM (SELECT `age` FROM `test_model` WHERE `age` > ? GROUP BY `age` HAVING COUNT(`id`) = ?) AS `t`;",
+				Args: []any{18, 1},
+			},
+		},
+		{
+			// 去重的时候统计的是去重之后的数量
+			name: "distinct",
+			s: NewSelector[TestModel](db).Select(C("FirstName")).Distinct().
+				Where(C("Age").GT(18)).Limit(10),
+			wantQuery: &Query{
+				SQL:  "SELECT COUNT(*) FROM (SELECT DISTINCT `first_name` FROM `test_model` WHERE `age` > ?) AS `t`;",
+				Args: []any{18},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.s.buildCount()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+// countingRegistry 记录 Get 被调用的次数
+type countingRegistry struct {
+	model.Registry
+	cnt int
+}
+
+func (r *countingRegistry) Get(val any) (*model.Model, error) {
+	r.cnt++
+	return r.Registry.Get(val)
+}
+
+func TestSelector_ResolveModelOnce(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	r := &countingRegistry{Registry: model.NewRegistry()}
+	db, err := OpenDB(mockDB, DBWithRegistry(r))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT .*").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	s := NewSelector[TestModel](db).Where(C("Id").EQ(1))
+	_ = s.Debug()
+	_, err = s.Build()
+	require.NoError(t, err)
+	_, err = s.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, r.cnt)
+}
+
+// go test -bench=BenchmarkSelector_Build -benchmem
+// 同一个 Selector 重复 Build 的时候不会再解析元数据
+func BenchmarkSelector_Build(b *testing.B) {
+	db, err := Open("sqlite3", "file:benchmark_build.db?cache=shared&mode=memory")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("new selector", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err = NewSelector[TestModel](db).Where(C("Id").EQ(1)).Build(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reuse selector", func(b *testing.B) {
+		s := NewSelector[TestModel](db).Where(C("Id").EQ(1))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err = s.Build(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
 }
 
 // 在 orm 目录下执行
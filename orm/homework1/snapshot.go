@@ -0,0 +1,90 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snapshot 将 Query 序列化为稳定的字符串，适合用于 golden file 测试。
+// 输出的格式是：
+//
+//	SQL: SELECT * FROM `test_model` WHERE `id` = ?;
+//	Args:
+//	  [0] int: 1
+//
+// 每个参数都会带上类型。指针会被解引用，所以输出不会因为地址不同而变化；
+// 时间会被转换为 UTC 的 RFC3339 格式，所以输出不会因为时区和单调时钟而变化
+func (q *Query) Snapshot() string {
+	var sb strings.Builder
+	sb.WriteString("SQL: ")
+	sb.WriteString(q.SQL)
+	sb.WriteString("\nArgs:")
+	if len(q.Args) == 0 {
+		sb.WriteString(" []")
+	}
+	for i, arg := range q.Args {
+		sb.WriteString(fmt.Sprintf("\n  [%d] %T: %s", i, arg, snapshotValue(reflect.ValueOf(arg))))
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// snapshotValue 输出 val 的稳定表示。
+// 时间统一转换为 UTC，并且去掉单调时钟的读数；结构体逐个字段输出，指针会被解引用
+func snapshotValue(val reflect.Value) string {
+	if !val.IsValid() {
+		return "nil"
+	}
+	if val.Type() == timeType && val.CanInterface() {
+		return val.Interface().(time.Time).UTC().Format(time.RFC3339Nano)
+	}
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return "nil"
+		}
+		return "&" + snapshotValue(val.Elem())
+	case reflect.String:
+		return strconv.Quote(val.String())
+	case reflect.Struct:
+		typ := val.Type()
+		fds := make([]string, 0, val.NumField())
+		for i := 0; i < val.NumField(); i++ {
+			fds = append(fds, typ.Field(i).Name+":"+snapshotValue(val.Field(i)))
+		}
+		return "{" + strings.Join(fds, " ") + "}"
+	case reflect.Slice, reflect.Array:
+		if val.Kind() == reflect.Slice && val.IsNil() {
+			return "nil"
+		}
+		if val.Type().Elem().Kind() == reflect.Uint8 && val.Kind() == reflect.Slice {
+			return strconv.Quote(string(val.Bytes()))
+		}
+		elems := make([]string, 0, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			elems = append(elems, snapshotValue(val.Index(i)))
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	case reflect.Map:
+		if val.IsNil() {
+			return "nil"
+		}
+		// map 的遍历顺序是随机的，所以要排序
+		kvs := make([]string, 0, val.Len())
+		iter := val.MapRange()
+		for iter.Next() {
+			kvs = append(kvs, snapshotValue(iter.Key())+":"+snapshotValue(iter.Value()))
+		}
+		sort.Strings(kvs)
+		return "map[" + strings.Join(kvs, " ") + "]"
+	}
+	// 剩下的都是基本类型，例如整数和浮点数，
+	// 直接输出 reflect.Value，这样没有导出的字段也可以输出
+	return fmt.Sprintf("%v", val)
+}
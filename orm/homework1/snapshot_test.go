@@ -0,0 +1,109 @@
+package orm
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestQuery_Snapshot(t *testing.T) {
+	// TimeHolder 用于测试结构体里面的时间指针
+	type TimeHolder struct {
+		At *time.Time
+	}
+	db := memoryDB(t)
+	testCases := []struct {
+		name     string
+		q        QueryBuilder
+		wantSnap string
+	}{
+		{
+			name:     "no args",
+			q:        NewSelector[TestModel](db),
+			wantSnap: "SQL: SELECT * FROM `test_model`;\nArgs: []\n",
+		},
+		{
+			name: "complex query",
+			q: NewSelector[TestModel](db).
+				Select(C("Age"), Count("Id").As("cnt")).
+				Where(C("FirstName").EQ("Tom"),
+					C("LastName").EQ(&sql.NullString{String: "Jerry", Valid: true}),
+					C("Id").GT(NewSelector[TestModel](db).
+						Select(Min("Id")).Where(C("Age").LT(int8(18))).AsSubquery("sub"))).
+				GroupBy(C("Age")).
+				Having(Count("Id").EQ(3)).
+				OrderBy(Desc(C("Age"))).
+				Limit(10).Offset(20),
+			wantSnap: "SQL: SELECT `age`,COUNT(`id`) AS `cnt` FROM `test_model` " +
+				"WHERE `first_name` = ? AND `last_name` = ? AND `id` > (SELECT MIN(`id`) FROM `test_model` WHERE `age` < ?) " +
+				"GROUP BY `age` HAVING COUNT(`id`) = ? ORDER BY `age` DESC LIMIT ? OFFSET ?;\n" +
+				"Args:\n" +
+				"  [0] string: \"Tom\"\n" +
+				"  [1] *sql.NullString: &{String:\"Jerry\" Valid:true}\n" +
+				"  [2] int8: 18\n" +
+				"  [3] int: 3\n" +
+				"  [4] int: 10\n" +
+				"  [5] int: 20\n",
+		},
+		{
+			// 时间转换为 UTC，结构体里面的时间也是如此
+			name: "time",
+			q: func() QueryBuilder {
+				cst := time.FixedZone("CST", 8*3600)
+				return NewSelector[TestModel](db).Where(
+					C("Age").GT(time.Date(2022, 10, 10, 8, 0, 0, 500, cst)),
+					C("Age").LT(sql.NullTime{Time: time.Date(2022, 10, 11, 8, 0, 0, 0, cst), Valid: true}),
+					C("Id").EQ(&TimeHolder{At: &time.Time{}}))
+			}(),
+			wantSnap: "SQL: SELECT * FROM `test_model` WHERE `age` > ? AND `age` < ? AND `id` = ?;\n" +
+				"Args:\n" +
+				"  [0] time.Time: 2022-10-10T00:00:00.0000005Z\n" +
+				"  [1] sql.NullTime: {Time:2022-10-11T00:00:00Z Valid:true}\n" +
+				"  [2] *orm.TimeHolder: &{At:&0001-01-01T00:00:00Z}\n",
+		},
+		{
+			name: "join",
+			q: func() QueryBuilder {
+				m1 := TableOf[TestModel]().As("m1")
+				m2 := TableOf[TestModel]().As("m2")
+				return NewSelector[TestModel](db).Select(m1.C("Id"), m2.C("FirstName")).
+					From(m1.LeftJoin(m2).On(m1.C("Id").EQ(m2.C("Age")), m2.C("FirstName").EQ("Tom"))).
+					Where(m1.C("Age").GT(int8(18)))
+			}(),
+			wantSnap: "SQL: SELECT `m1`.`id`,`m2`.`first_name` FROM `test_model` AS `m1` " +
+				"LEFT JOIN `test_model` AS `m2` ON `m1`.`id` = `m2`.`age` AND `m2`.`first_name` = ? " +
+				"WHERE `m1`.`age` > ?;\n" +
+				"Args:\n" +
+				"  [0] string: \"Tom\"\n" +
+				"  [1] int8: 18\n",
+		},
+		{
+			// 子查询的参数在 ON 和 WHERE 的参数之前
+			name: "join subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[TestModel](db).Select(C("Id"), Count("Age").As("cnt")).
+					Where(C("Age").LT(int8(30))).GroupBy(C("Id")).AsSubquery("t")
+				tm := TableOf[TestModel]().As("m")
+				return NewSelector[TestModel](db).Select(tm.C("FirstName"), sub.C("cnt")).
+					From(tm.Join(sub).On(tm.C("Id").EQ(sub.C("Id")))).
+					Where(tm.C("FirstName").EQ("Tom"))
+			}(),
+			wantSnap: "SQL: SELECT `m`.`first_name`,`t`.`cnt` FROM `test_model` AS `m` JOIN " +
+				"(SELECT `id`,COUNT(`age`) AS `cnt` FROM `test_model` WHERE `age` < ? GROUP BY `id`) AS `t` " +
+				"ON `m`.`id` = `t`.`id` WHERE `m`.`first_name` = ?;\n" +
+				"Args:\n" +
+				"  [0] int8: 30\n" +
+				"  [1] string: \"Tom\"\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.wantSnap, q.Snapshot())
+		})
+	}
+}
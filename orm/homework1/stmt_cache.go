@@ -0,0 +1,102 @@
+package orm
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// stmtCache 以 SQL 为键缓存预编译的语句，超过容量之后淘汰最久没有使用的语句。
+// 被淘汰的语句如果还在使用中，那么等到使用完毕之后才会关闭
+type stmtCache struct {
+	mu   sync.Mutex
+	size int
+	// ll 按照最近使用的顺序保存 *stmtEntry，最近使用的在前面
+	ll    *list.List
+	stmts map[string]*list.Element
+}
+
+type stmtEntry struct {
+	query string
+	stmt  *sql.Stmt
+	// refs 是正在使用该语句的调用者数量
+	refs int
+	// evicted 为 true 说明已经被淘汰，最后一个使用者负责关闭
+	evicted bool
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		ll:    list.New(),
+		stmts: make(map[string]*list.Element, size),
+	}
+}
+
+// get 返回 query 对应的预编译语句，没有的话就预编译并且放入缓存。
+// 用完之后必须调用返回的 release
+func (c *stmtCache) get(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, func(), error) {
+	c.mu.Lock()
+	if ele, ok := c.stmts[query]; ok {
+		c.ll.MoveToFront(ele)
+		entry := ele.Value.(*stmtEntry)
+		entry.refs++
+		c.mu.Unlock()
+		return entry.stmt, c.releaseFunc(entry), nil
+	}
+	c.mu.Unlock()
+
+	// 预编译需要和数据库交互，所以不能持有锁
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 别的 goroutine 已经预编译了同样的语句
+	if ele, ok := c.stmts[query]; ok {
+		_ = stmt.Close()
+		c.ll.MoveToFront(ele)
+		entry := ele.Value.(*stmtEntry)
+		entry.refs++
+		return entry.stmt, c.releaseFunc(entry), nil
+	}
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.stmts[query] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.size {
+		c.evict(c.ll.Back())
+	}
+	return stmt, c.releaseFunc(entry), nil
+}
+
+func (c *stmtCache) releaseFunc(entry *stmtEntry) func() {
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		entry.refs--
+		if entry.evicted && entry.refs == 0 {
+			_ = entry.stmt.Close()
+		}
+	}
+}
+
+// evict 将语句移出缓存，没有人在使用的话立刻关闭
+func (c *stmtCache) evict(ele *list.Element) {
+	entry := c.ll.Remove(ele).(*stmtEntry)
+	delete(c.stmts, entry.query)
+	entry.evicted = true
+	if entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// close 淘汰所有的语句
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.evict(c.ll.Back())
+	}
+}
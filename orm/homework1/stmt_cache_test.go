@@ -0,0 +1,134 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStmtCache_Evict(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	mock.ExpectPrepare("SELECT 1").WillBeClosed()
+	mock.ExpectPrepare("SELECT 2")
+	// SELECT 1 最早被使用，所以被淘汰
+	mock.ExpectPrepare("SELECT 3")
+
+	c := newStmtCache(2)
+	ctx := context.Background()
+	for _, query := range []string{"SELECT 1", "SELECT 2", "SELECT 2", "SELECT 3"} {
+		_, release, err := c.get(ctx, mockDB, query)
+		require.NoError(t, err)
+		release()
+	}
+	assert.Equal(t, 2, c.ll.Len())
+	assert.NotContains(t, c.stmts, "SELECT 1")
+	assert.Contains(t, c.stmts, "SELECT 2")
+	assert.Contains(t, c.stmts, "SELECT 3")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStmtCache_EvictInUse(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+
+	prep := mock.ExpectPrepare("SELECT 1").WillBeClosed()
+	mock.ExpectPrepare("SELECT 2")
+	prep.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	c := newStmtCache(1)
+	ctx := context.Background()
+	stmt, release1, err := c.get(ctx, mockDB, "SELECT 1")
+	require.NoError(t, err)
+	_, release2, err := c.get(ctx, mockDB, "SELECT 2")
+	require.NoError(t, err)
+	release2()
+
+	// 已经被淘汰，但是还在使用，所以没有关闭
+	assert.NotContains(t, c.stmts, "SELECT 1")
+	rows, err := stmt.QueryContext(ctx)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	// 最后一个使用者释放之后关闭
+	release1()
+	_, err = stmt.QueryContext(ctx)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDB_StmtCache(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := OpenDB(mockDB, DBWithStmtCache(8))
+	require.NoError(t, err)
+
+	// 同样的查询只预编译一次
+	prep := mock.ExpectPrepare("SELECT .*").WillBeClosed()
+	prep.ExpectQuery().WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	prep.ExpectQuery().WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectPrepare("DELETE .*").WillBeClosed().
+		ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectClose()
+
+	ctx := context.Background()
+	res, err := NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 1}, res)
+	res, err = NewSelector[TestModel](db).Where(C("Id").EQ(2)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &TestModel{Id: 2}, res)
+	_, err = NewDeleter[TestModel](db).Where(C("Id").EQ(1)).Exec(ctx)
+	require.NoError(t, err)
+
+	// 关闭 DB 的时候关闭所有缓存的语句
+	require.NoError(t, db.Close())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func BenchmarkDB_StmtCache(b *testing.B) {
+	testCases := []struct {
+		name string
+		opts []DBOption
+	}{
+		{
+			name: "uncached",
+		},
+		{
+			name: "cached",
+			opts: []DBOption{DBWithStmtCache(16)},
+		},
+	}
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			db, err := Open("sqlite3", "file:stmt_bench.db?cache=shared&mode=memory", tc.opts...)
+			require.NoError(b, err)
+			defer func() { _ = db.Close() }()
+			_, err = db.db.Exec(TestModel{}.CreateSQL())
+			require.NoError(b, err)
+			ctx := context.Background()
+			_, err = NewInserter[TestModel](db).Values(&TestModel{
+				Id:        1,
+				FirstName: "Tom",
+				Age:       18,
+				LastName:  &sql.NullString{String: "Jerry", Valid: true},
+			}).Exec(ctx)
+			require.NoError(b, err)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err = NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package orm
+
+var _ TableReference = Subquery{}
+
+// Subquery 代表一个子查询，它既可以作为标量子查询出现在查询条件里面，例如
+// C("Age").GT(NewSelector[User](db).Select(Avg("Age")).AsSubquery("sub"))
+// 也可以作为派生表出现在 FROM 后面，例如
+// NewSelector[User](db).From(NewSelector[User](db).AsSubquery("t"))
+type Subquery struct {
+	// 使用 QueryBuilder 仅仅是为了让 Subquery 可以是非泛型的。
+	s     QueryBuilder
+	alias string
+	// columns 是子查询选择的列，为空代表 SELECT *
+	columns []Selectable
+	// table 是子查询的 FROM 部分，用于查找外层查询用到的列
+	table TableReference
+}
+
+func (Subquery) expr() {}
+
+func (s Subquery) tableAlias() string {
+	return s.alias
+}
+
+// As 指定子查询的别名
+func (s Subquery) As(alias string) Subquery {
+	s.alias = alias
+	return s
+}
+
+// C 返回属于这个子查询的列，name 可以是子查询里面列的别名，
+// 或者是子查询选择的没有别名的字段
+func (s Subquery) C(name string) Column {
+	return Column{
+		table: s,
+		name:  name,
+	}
+}
+
+func (s Subquery) Join(target TableReference) *JoinBuilder {
+	return newJoinBuilder(s, target, "JOIN")
+}
+
+func (s Subquery) LeftJoin(target TableReference) *JoinBuilder {
+	return newJoinBuilder(s, target, "LEFT JOIN")
+}
+
+func (s Subquery) RightJoin(target TableReference) *JoinBuilder {
+	return newJoinBuilder(s, target, "RIGHT JOIN")
+}
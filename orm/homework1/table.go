@@ -0,0 +1,109 @@
+package orm
+
+// TableReference 代表 FROM 后面可以出现的东西，
+// 目前有普通的表 Table，JOIN 查询 Join，子查询 Subquery 和原生表达式 RawExpr
+type TableReference interface {
+	tableAlias() string
+}
+
+var (
+	_ TableReference = Table{}
+	_ TableReference = Join{}
+	_ TableReference = RawExpr{}
+)
+
+// Table 代表一个模型对应的表
+type Table struct {
+	entity any
+	alias  string
+}
+
+// TableOf 例如 TableOf[Order]()，代表 Order 对应的表
+func TableOf[T any]() Table {
+	return Table{
+		entity: new(T),
+	}
+}
+
+func (t Table) tableAlias() string {
+	return t.alias
+}
+
+// As 指定表的别名，例如 TableOf[Order]().As("o")
+func (t Table) As(alias string) Table {
+	return Table{
+		entity: t.entity,
+		alias:  alias,
+	}
+}
+
+// C 返回属于这个表的列，在 JOIN 查询里面用于区分不同表的同名列
+func (t Table) C(name string) Column {
+	return Column{
+		table: t,
+		name:  name,
+	}
+}
+
+func (t Table) Join(target TableReference) *JoinBuilder {
+	return newJoinBuilder(t, target, "JOIN")
+}
+
+func (t Table) LeftJoin(target TableReference) *JoinBuilder {
+	return newJoinBuilder(t, target, "LEFT JOIN")
+}
+
+func (t Table) RightJoin(target TableReference) *JoinBuilder {
+	return newJoinBuilder(t, target, "RIGHT JOIN")
+}
+
+// JoinBuilder 用于构造 Join，必须调用 On 才能得到 Join
+type JoinBuilder struct {
+	left  TableReference
+	right TableReference
+	typ   string
+}
+
+func newJoinBuilder(left, right TableReference, typ string) *JoinBuilder {
+	return &JoinBuilder{
+		left:  left,
+		right: right,
+		typ:   typ,
+	}
+}
+
+// On 指定连接条件，多个条件之间使用 AND 连接
+func (j *JoinBuilder) On(ps ...Predicate) Join {
+	return Join{
+		left:  j.left,
+		right: j.right,
+		typ:   j.typ,
+		on:    ps,
+	}
+}
+
+// Join 代表 JOIN 查询，例如
+// TableOf[Order]().Join(TableOf[User]()).On(C("UserId").EQ(C("Id")))
+type Join struct {
+	left  TableReference
+	right TableReference
+	typ   string
+	on    []Predicate
+}
+
+// tableAlias Join 本身没有别名
+func (j Join) tableAlias() string {
+	return ""
+}
+
+func (j Join) Join(target TableReference) *JoinBuilder {
+	return newJoinBuilder(j, target, "JOIN")
+}
+
+func (j Join) LeftJoin(target TableReference) *JoinBuilder {
+	return newJoinBuilder(j, target, "LEFT JOIN")
+}
+
+func (j Join) RightJoin(target TableReference) *JoinBuilder {
+	return newJoinBuilder(j, target, "RIGHT JOIN")
+}
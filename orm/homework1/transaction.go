@@ -0,0 +1,52 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+)
+
+var (
+	_ Session = &DB{}
+	_ Session = &Tx{}
+)
+
+// Session 代表一个会话，DB 和 Tx 都实现了它。
+// 各种语句构造器都依赖于 Session 执行语句，
+// 所以同样的构造器既可以直接执行，也可以在事务里面执行
+type Session interface {
+	// getDB 返回方言，元数据注册中心这些配置所在的 DB
+	getDB() *DB
+	queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	queryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	execContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Tx 代表一个事务
+type Tx struct {
+	tx *sql.Tx
+	db *DB
+}
+
+func (t *Tx) getDB() *DB {
+	return t.db
+}
+
+func (t *Tx) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+func (t *Tx) queryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+func (t *Tx) execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,121 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func txTestDB(t *testing.T) *DB {
+	db := memoryDBWithDB("tx_test", t)
+	// 内存数据库在所有连接关闭之后就会被销毁
+	db.db.SetMaxOpenConns(1)
+	_, err := db.db.Exec(TestModel{}.CreateSQL())
+	require.NoError(t, err)
+	_, err = NewDeleter[TestModel](db).Exec(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func newTxTestModel(id int64) *TestModel {
+	return &TestModel{
+		Id:        id,
+		FirstName: "Tom",
+		Age:       18,
+		LastName:  &sql.NullString{String: "Jerry", Valid: true},
+	}
+}
+
+func TestTx_CommitAndRollback(t *testing.T) {
+	db := txTestDB(t)
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = NewInserter[TestModel](tx).Values(newTxTestModel(1)).Exec(ctx)
+	require.NoError(t, err)
+	// 事务内部能够看到自己插入的数据
+	res, err := NewSelector[TestModel](tx).Where(C("Id").EQ(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, newTxTestModel(1), res)
+	require.NoError(t, tx.Rollback())
+
+	// 回滚之后数据不存在
+	_, err = NewSelector[TestModel](db).Where(C("Id").EQ(1)).Get(ctx)
+	assert.Equal(t, ErrNoRows, err)
+
+	tx, err = db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = NewInserter[TestModel](tx).Values(newTxTestModel(2)).Exec(ctx)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	res, err = NewSelector[TestModel](db).Where(C("Id").EQ(2)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, newTxTestModel(2), res)
+
+	// 已经提交的事务不能再回滚
+	assert.Equal(t, sql.ErrTxDone, tx.Rollback())
+}
+
+func TestDB_DoTx(t *testing.T) {
+	testCases := []struct {
+		name string
+		fn   func(ctx context.Context, tx *Tx) error
+
+		wantErr   error
+		wantPanic bool
+		wantRows  int
+	}{
+		{
+			name: "commit",
+			fn: func(ctx context.Context, tx *Tx) error {
+				_, err := NewInserter[TestModel](tx).Values(newTxTestModel(1)).Exec(ctx)
+				return err
+			},
+			wantRows: 1,
+		},
+		{
+			name: "rollback on error",
+			fn: func(ctx context.Context, tx *Tx) error {
+				_, err := NewInserter[TestModel](tx).Values(newTxTestModel(1)).Exec(ctx)
+				if err != nil {
+					return err
+				}
+				return errors.New("mock error")
+			},
+			wantErr: errors.New("mock error"),
+		},
+		{
+			name: "rollback on panic",
+			fn: func(ctx context.Context, tx *Tx) error {
+				_, err := NewInserter[TestModel](tx).Values(newTxTestModel(1)).Exec(ctx)
+				if err != nil {
+					return err
+				}
+				panic("mock panic")
+			},
+			wantPanic: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := txTestDB(t)
+			ctx := context.Background()
+			if tc.wantPanic {
+				assert.PanicsWithValue(t, "mock panic", func() {
+					_ = db.DoTx(ctx, tc.fn, nil)
+				})
+			} else {
+				err := db.DoTx(ctx, tc.fn, nil)
+				assert.Equal(t, tc.wantErr, err)
+			}
+			res, err := NewSelector[TestModel](db).GetMulti(ctx)
+			require.NoError(t, err)
+			assert.Len(t, res, tc.wantRows)
+		})
+	}
+}
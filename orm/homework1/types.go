@@ -2,7 +2,6 @@ package orm
 
 import (
 	"context"
-	"database/sql"
 )
 
 type Querier[T any] interface {
@@ -10,8 +9,10 @@ type Querier[T any] interface {
 	GetMulti(ctx context.Context) ([]*T, error)
 }
 
+// Executor 执行 INSERT, UPDATE 和 DELETE 语句。
+// 返回的 error 也会记录在 Result 里面
 type Executor interface {
-	Exec(ctx context.Context) (sql.Result, error)
+	Exec(ctx context.Context) (Result, error)
 }
 
 type Query struct {
@@ -0,0 +1,104 @@
+package orm
+
+import (
+	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+)
+
+var _ Executor = &Updater[any]{}
+
+// Updater 用于构造 UPDATE 语句
+type Updater[T any] struct {
+	builder
+	assigns []Assignable
+	where   []Predicate
+}
+
+func NewUpdater[T any](sess Session) *Updater[T] {
+	return &Updater[T]{
+		builder: newBuilder(sess),
+	}
+}
+
+// Set 指定要更新的列，例如：
+// Set(Assign("Age", 30), Assign("Age", C("Age").Add(1)))
+func (u *Updater[T]) Set(assigns ...Assignable) *Updater[T] {
+	u.assigns = assigns
+	return u
+}
+
+// Where 用于构造 WHERE 查询条件。如果 ps 长度为 0，那么不会构造 WHERE 部分，
+// 也就是会更新全部数据
+func (u *Updater[T]) Where(ps ...Predicate) *Updater[T] {
+	u.where = ps
+	return u
+}
+
+func (u *Updater[T]) Build() (*Query, error) {
+	if len(u.assigns) == 0 {
+		return nil, errs.ErrNoUpdatedColumns
+	}
+	var (
+		t   T
+		err error
+	)
+	if err = u.resolveModel(&t); err != nil {
+		return nil, err
+	}
+	if u.model.ReadOnly {
+		return nil, errs.NewErrReadOnlyModel(u.model.TableName)
+	}
+	u.sb.Reset()
+	u.args = nil
+
+	u.sb.WriteString("UPDATE ")
+	if err = u.quote(u.model.TableName); err != nil {
+		return nil, err
+	}
+	u.sb.WriteString(" SET ")
+	for i, a := range u.assigns {
+		if i > 0 {
+			u.sb.WriteByte(',')
+		}
+		assign, ok := a.(Assignment)
+		if !ok {
+			return nil, errs.NewErrUnsupportedAssignable(a)
+		}
+		if err = u.buildColumn(assign.column, ""); err != nil {
+			return nil, err
+		}
+		u.sb.WriteByte('=')
+		if err = u.buildExpression(assign.val); err != nil {
+			return nil, err
+		}
+	}
+	if len(u.where) > 0 {
+		u.sb.WriteString(" WHERE ")
+		if err = u.buildPredicates(u.where); err != nil {
+			return nil, err
+		}
+	}
+	u.sb.WriteByte(';')
+	return &Query{
+		SQL:  u.sb.String(),
+		Args: u.args,
+	}, nil
+}
+
+// ToSQL 返回 Build 构造的 SQL 和参数，不会执行
+func (u *Updater[T]) ToSQL() (string, []any, error) {
+	return toSQL(u.Build())
+}
+
+func (u *Updater[T]) Exec(ctx context.Context) (Result, error) {
+	q, err := u.Build()
+	if err != nil {
+		return Result{err: err}, err
+	}
+	ctx, cancel, err := u.db.prepareContext(ctx, "Updater")
+	if err != nil {
+		return Result{err: err}, err
+	}
+	defer cancel()
+	return u.exec(ctx, "UPDATE", q)
+}
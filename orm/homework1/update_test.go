@@ -0,0 +1,142 @@
+package orm
+
+import (
+	"context"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/internal/errs"
+	"gitee.com/geektime-geekbang/geektime-go/orm/homework1/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestUpdater_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "no set",
+			q:       NewUpdater[TestModel](db),
+			wantErr: errs.ErrNoUpdatedColumns,
+		},
+		{
+			name: "single column",
+			q:    NewUpdater[TestModel](db).Set(Assign("Age", 18)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "multiple columns",
+			q:    NewUpdater[TestModel](db).Set(Assign("Age", 18), Assign("FirstName", "Tom")),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=?,`first_name`=?;",
+				Args: []any{18, "Tom"},
+			},
+		},
+		{
+			// 自增
+			name: "increment",
+			q:    NewUpdater[TestModel](db).Set(Assign("Age", C("Age").Add(1))),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=`age` + ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			// 自减
+			name: "decrement",
+			q:    NewUpdater[TestModel](db).Set(Assign("Age", C("Age").Sub(1))),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=`age` - ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "multi",
+			q:    NewUpdater[TestModel](db).Set(Assign("Age", C("Age").Add(1).Multi(2))),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age`=(`age` + ?) * ?;",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			// 参数先是 SET 部分，再是 WHERE 部分
+			name: "where",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("FirstName", "Tom"), Assign("Age", C("Age").Add(1))).
+				Where(C("Id").EQ(12).Or(C("Age").LT(18))),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name`=?,`age`=`age` + ? WHERE `id` = ? OR `age` < ?;",
+				Args: []any{"Tom", 1, 12, 18},
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewUpdater[TestModel](db).Set(Assign("Invalid", 1)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "unsupported assignable",
+			q:       NewUpdater[TestModel](db).Set(C("Age")),
+			wantErr: errs.NewErrUnsupportedAssignable(C("Age")),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestUpdater_ReadOnlyModel(t *testing.T) {
+	type UpdateView struct {
+		Id int64
+	}
+	r := model.NewRegistry()
+	if _, err := r.Register(&UpdateView{}, model.WithReadOnly()); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open("sqlite3", "file:test.db?cache=shared&mode=memory", DBWithRegistry(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewUpdater[UpdateView](db).Set(Assign("Id", 1)).Build()
+	assert.Equal(t, errs.NewErrReadOnlyModel("update_view"), err)
+}
+
+func TestUpdater_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDB(mockDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `test_model` SET `age`=`age` + ? WHERE `id` = ?;")).
+		WithArgs(1, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	res, err := NewUpdater[TestModel](db).Set(Assign("Age", C("Age").Add(1))).
+		Where(C("Id").EQ(12)).Exec(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	affected, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}